@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/glibtools/libs/j2rpc"
+)
+
+var (
+	// RPCServerFunc return the server whose methods are generated by the gen-ts command,
+	// the methods must be registered when it returns
+	RPCServerFunc func() j2rpc.Server
+
+	genTSOutput string
+
+	genTSCmd = &cobra.Command{
+		Use:   "gen-ts",
+		Short: "generate the typescript client of the rpc methods",
+		Run: func(*cobra.Command, []string) {
+			if RPCServerFunc == nil {
+				log.Fatalln("gen-ts: cmd.RPCServerFunc isn't set")
+			}
+			var w io.Writer = os.Stdout
+			if genTSOutput != "" {
+				if err := os.MkdirAll(filepath.Dir(genTSOutput), 0755); err != nil {
+					log.Fatalf("gen-ts: %s", err.Error())
+				}
+				file, err := os.Create(genTSOutput)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				defer func() { _ = file.Close() }()
+				w = file
+			}
+			cobra.CheckErr(j2rpc.GenerateTypeScript(RPCServerFunc(), w))
+		},
+	}
+)
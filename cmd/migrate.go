@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/glibtools/libs/mdb"
+)
+
+var (
+	// MigrateDBFunc initialize the databases and register the models and the migrations before the migrate commands,
+	// e.g. mdb.DB.DBInitializationWithViper(config.C.V(), bus) without migrating
+	MigrateDBFunc func()
+
+	migrateDB    string
+	migrateSteps int
+
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "apply, roll back or show the database migrations",
+	}
+
+	migrateUpCmd = &cobra.Command{
+		Use:   "up",
+		Short: "auto migrate the models and apply the pending migrations",
+		Run: func(*cobra.Command, []string) {
+			for _, g := range migrateDBs(true) {
+				applied, err := g.MigrateUp(context.Background())
+				if err != nil {
+					log.Fatalf("migrate %s: %s", migrateDBName(g), err.Error())
+				}
+				log.Printf("migrate %s: %d applied\n", migrateDBName(g), len(applied))
+			}
+		},
+	}
+
+	migrateDownCmd = &cobra.Command{
+		Use:   "down",
+		Short: "roll back the last applied migrations",
+		Run: func(*cobra.Command, []string) {
+			g := migrateDBs(false)[0]
+			rolled, err := g.MigrateDown(context.Background(), migrateSteps)
+			if err != nil {
+				log.Fatalf("migrate %s: %s", migrateDBName(g), err.Error())
+			}
+			log.Printf("migrate %s: %d rolled back\n", migrateDBName(g), len(rolled))
+		},
+	}
+
+	migrateStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "show the states of the migrations",
+		Run: func(*cobra.Command, []string) {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "DB\tMODULE\tVERSION\tNAME\tSTATE\tAPPLIED AT")
+			for _, g := range migrateDBs(true) {
+				statuses, err := g.MigrationStatuses()
+				if err != nil {
+					log.Fatalf("migrate %s: %s", migrateDBName(g), err.Error())
+				}
+				for _, s := range statuses {
+					appliedAt := "-"
+					if s.AppliedAt != nil {
+						appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+						migrateDBName(g), s.Module, s.Version, s.Name, s.State, appliedAt)
+				}
+			}
+			_ = w.Flush()
+		},
+	}
+)
+
+// migrateDBs initialize the databases and return the one of --db, or all of them when all is true and --db is empty
+func migrateDBs(all bool) []*mdb.GormDB {
+	if BeforeStartFunc != nil {
+		BeforeStartFunc()
+	}
+	if MigrateDBFunc == nil {
+		log.Fatalln("migrate: cmd.MigrateDBFunc isn't set")
+	}
+	MigrateDBFunc()
+	if migrateDB != "" || !all {
+		g, has := mdb.GetDB(migrateDB)
+		if !has || g.DB == nil {
+			log.Fatalf("migrate: database %s isn't initialized", migrateDB)
+		}
+		return []*mdb.GormDB{g}
+	}
+	dbs := []*mdb.GormDB{mdb.DB}
+	for _, name := range mdb.DBNames() {
+		dbs = append(dbs, mdb.MustGetDB(name))
+	}
+	return dbs
+}
+
+func migrateDBName(g *mdb.GormDB) string {
+	if g.Name() == "" {
+		return mdb.DefaultDBName
+	}
+	return g.Name()
+}
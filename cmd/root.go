@@ -15,6 +15,12 @@ func init() {
 	RootCmd.AddCommand(startCmd)
 	startCmd.Flags().BoolVarP(&daemon, "daemon", "d", false, "run as daemon")
 	RootCmd.AddCommand(stopCmd)
+	RootCmd.AddCommand(genTSCmd)
+	genTSCmd.Flags().StringVarP(&genTSOutput, "output", "o", "", "output file, stdout when empty")
+	RootCmd.AddCommand(migrateCmd)
+	migrateCmd.PersistentFlags().StringVar(&migrateDB, "db", "", "database name, all the databases of up and status when empty")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	migrateDownCmd.Flags().IntVarP(&migrateSteps, "steps", "n", 1, "number of the migrations to roll back")
 }
 
 var (
@@ -28,6 +28,10 @@ type AppStart struct {
 	WebLogger *midLogger
 	Captcha   *Captcha
 	RPC       j2rpc.Server
+	// RPCWebSocket is the websocket endpoint of RPC, it is nil when it isn't enabled
+	RPCWebSocket *j2rpc.WebSocket
+	// RPCSSE is the server-sent events endpoint of RPC, it is nil when it isn't enabled
+	RPCSSE *j2rpc.SSE
 
 	rootParty iris.Party
 	stop      chan struct{}
@@ -46,9 +50,11 @@ func (a *AppStart) Constructor() {
 
 func (a *AppStart) DefaultMiddlewares(p iris.Party) {
 	p.UseRouter(logger.New(), recover.New(),
-		cors.New().ExposeHeaders("X-Server", "Authorization", "X-Authorization", "Request-Id", "X-Request-Id", "X-Crypto").
+		cors.New().ExposeHeaders("X-Server", "Authorization", "X-Authorization", "Request-Id", "X-Request-Id", "X-Crypto",
+			j2rpc.IdempotencyReplayedHeader, j2rpc.CacheStatusHeader, "ETag").
 			AllowHeaders("Authorization", "X-Authorization", "Request-Id", "X-Request-Id", "X-Server", "Token",
-				"Accept", "Accept-Language", "Content-Language", "Content-Type", "X-Crypto").Handler(),
+				"Accept", "Accept-Language", "Content-Language", "Content-Type", "X-Crypto",
+				j2rpc.IdempotencyKeyHeader, j2rpc.TimeoutHeader, j2rpc.TraceParentHeader).Handler(),
 	)
 }
 
@@ -90,6 +96,8 @@ func (a *AppStart) RouteApp(middleware ...iris.Handler) iris.Party {
 	p.Get("/captcha", a.Captcha.Captcha())
 	if a.RPC != nil {
 		p.Post("/rpc", RPCServer2IrisHandler(a.RPC))
+		// the safe methods are invoked by GET /rpc/{method}?params=...
+		p.Get("/rpc/{method}", RPCServer2IrisHandler(a.RPC))
 	}
 	return p
 }
@@ -167,6 +175,17 @@ type RPCServerOption struct {
 	RateLimitBurst  int
 	ServerHost      string
 	PPROFPort       string
+	// DiscoverPath is the http GET path of the OpenRPC document, empty means disabled
+	DiscoverPath string
+	// WebSocketPath is the websocket path of the rpc server, empty means disabled
+	WebSocketPath string
+	// WebSocketOptions are the options of the websocket endpoint, e.g. j2rpc.WithWebSocketCheckOrigin
+	WebSocketOptions []j2rpc.WebSocketOptionFunc
+	// SSEPath is the server-sent events path of the rpc server, empty means disabled
+	SSEPath string
+	// StreamLimit is the max number of the open websocket and event-stream connections,
+	// they aren't counted by ConcurrentLimit, default ConcurrentLimit
+	StreamLimit int
 
 	//ResetRPCArgs type is j2rpc.Option or J2rpcServerCallBackFunc
 	ResetRPCArgs []interface{}
@@ -206,10 +225,25 @@ func (r *RPCServerOption) Prepare(vp *viper.Viper) {
 	if r.PPROFPort == "" {
 		r.PPROFPort = vp.GetString("server.pprof_port")
 	}
+	if r.DiscoverPath == "" {
+		r.DiscoverPath = vp.GetString("server.rpc_discover_path")
+	}
+	if r.WebSocketPath == "" {
+		r.WebSocketPath = vp.GetString("server.rpc_websocket_path")
+	}
+	if r.SSEPath == "" {
+		r.SSEPath = vp.GetString("server.rpc_sse_path")
+	}
+	if r.StreamLimit == 0 {
+		r.StreamLimit = vp.GetInt("server.stream_limit")
+	}
 
 	if r.ConcurrentLimit <= 10 {
 		r.ConcurrentLimit = 2000
 	}
+	if r.StreamLimit <= 0 {
+		r.StreamLimit = r.ConcurrentLimit
+	}
 	if r.RateLimitValue <= 0 {
 		r.RateLimitValue = 20
 	}
@@ -232,10 +266,30 @@ func (r *RPCServerOption) StartIrisRPC(vp *viper.Viper, appBus interface{}) {
 	if r.BeforeRouteAppFunc != nil {
 		r.BeforeRouteAppFunc(app.RootParty())
 	}
+	// the long-lived connections hold their own slots, so they don't exhaust the ones of the calls
+	streamPaths := make([]string, 0, 2)
+	for _, path := range []string{r.WebSocketPath, r.SSEPath} {
+		if path != "" {
+			streamPaths = append(streamPaths, path)
+		}
+	}
 	app.RouteApp(
-		ConcurrentLimit(r.ConcurrentLimit),
+		ConcurrentLimit(r.ConcurrentLimit, streamPaths...),
 		RateLimiter(r.RateLimitValue, r.RateLimitBurst),
 	)
+	if r.DiscoverPath != "" && app.RPC != nil {
+		app.RootParty().Get(r.DiscoverPath, HttpHandler2IrisHandler(j2rpc.DiscoverHandler(app.RPC)))
+	}
+	streamLimit := ConcurrentLimit(r.StreamLimit)
+	if r.WebSocketPath != "" && app.RPC != nil {
+		app.RPCWebSocket = j2rpc.NewWebSocket(app.RPC, r.WebSocketOptions...)
+		app.RootParty().Get(r.WebSocketPath, streamLimit, HttpHandler2IrisHandler(app.RPCWebSocket))
+	}
+	if r.SSEPath != "" && app.RPC != nil {
+		app.RPCSSE = j2rpc.NewSSE(app.RPC)
+		app.RootParty().Get(r.SSEPath, streamLimit, HttpHandler2IrisHandler(app.RPCSSE))
+		app.RootParty().Post(r.SSEPath, HttpHandler2IrisHandler(app.RPCSSE))
+	}
 	if r.AfterRouteAppFunc != nil {
 		r.AfterRouteAppFunc(app.RootParty())
 	}
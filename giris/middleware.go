@@ -61,9 +61,19 @@ func (m *midLogger) WebLogger() util.ItfLogger {
 	return m.webLogger
 }
 
-func ConcurrentLimit(n int) iris.Handler {
+// ConcurrentLimit limit the concurrent requests with 429, the GET requests of the skipped paths aren't counted,
+// e.g. the long-lived websocket and event-stream connections limited by their own ConcurrentLimit
+func ConcurrentLimit(n int, skipGetPaths ...string) iris.Handler {
 	ch := make(chan struct{}, n)
+	skip := make(map[string]bool, len(skipGetPaths))
+	for _, path := range skipGetPaths {
+		skip[path] = true
+	}
 	return func(c iris.Context) {
+		if c.Method() == http.MethodGet && skip[c.Path()] {
+			c.Next()
+			return
+		}
 		select {
 		case ch <- struct{}{}:
 			c.Next()
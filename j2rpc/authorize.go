@@ -0,0 +1,89 @@
+package j2rpc
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+// PermissionAll is the granted permission which allows every method
+const PermissionAll = "*"
+
+// Authorizer decide whether the caller of c is allowed to call the method which requires the permissions,
+// it returns an error when the call is denied. It is only called for the methods declaring permissions.
+type Authorizer func(c Context, method string, required []string) error
+
+// ImplCallerPermissions is the caller holding the granted permissions or roles,
+// e.g. the user stored under jwt.ContextUserKey
+type ImplCallerPermissions interface {
+	RPCGrantedPermissions() []string
+}
+
+// CallerResolver resolve the granted permissions of the caller, ok is false when there is no caller
+type CallerResolver func(c Context) (granted []string, ok bool)
+
+// Permissions return the required permissions of every method declaring them
+func (s *server) Permissions() map[string][]string {
+	permissions := make(map[string][]string)
+	for name, f := range s.router.funcs {
+		if len(f.meta.Permissions) > 0 {
+			permissions[name] = slices.Clone(f.meta.Permissions)
+		}
+	}
+	return permissions
+}
+
+// authorize check the permissions of the method, every denial is returned as ErrForbidden
+func (s *server) authorize(c Context, f funcInfo) error {
+	if len(f.meta.Permissions) == 0 {
+		return nil
+	}
+	authorizer := s.option.Authorizer
+	if authorizer == nil {
+		return NewError(ErrForbidden, "no authorizer for the protected method")
+	}
+	err := authorizer(c, f.name, f.meta.Permissions)
+	if err == nil {
+		return nil
+	}
+	var e ItfJ2rpcError
+	if errors.As(err, &e) {
+		return NewError(ErrForbidden, e.Error(), e.ErrorData())
+	}
+	return NewError(ErrForbidden, err.Error())
+}
+
+// ContextValueCaller resolve the caller from the context value of the key, e.g. jwt.ContextUserKey,
+// the value must implement ImplCallerPermissions
+func ContextValueCaller(key string) CallerResolver {
+	return func(c Context) ([]string, bool) {
+		v, has := c.GetValue(key)
+		if !has {
+			v = c.Value(key)
+		}
+		caller, ok := v.(ImplCallerPermissions)
+		if !ok {
+			return nil, false
+		}
+		return caller.RPCGrantedPermissions(), true
+	}
+}
+
+// PermissionAuthorizer allow the call when the caller resolved by resolve is granted any of the required permissions,
+// PermissionAll allows every method
+func PermissionAuthorizer(resolve CallerResolver) Authorizer {
+	return func(c Context, method string, required []string) error {
+		granted, ok := resolve(c)
+		if !ok {
+			return errors.New("missing caller")
+		}
+		for _, permission := range granted {
+			if permission == PermissionAll || slices.Contains(required, permission) {
+				return nil
+			}
+		}
+		sorted := slices.Clone(required)
+		sort.Strings(sorted)
+		return NewError(ErrForbidden, "permission denied", map[string]interface{}{"method": method, "required": sorted})
+	}
+}
@@ -0,0 +1,178 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultBatchLimit       = 100
+	defaultBatchConcurrency = 1
+)
+
+// responseRecorder is the http.ResponseWriter of a buffered context,
+// it records whatever a handler writes directly to the writer
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Header() http.Header { return w.header }
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *responseRecorder) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// message convert the recorded output to a response message
+func (w *responseRecorder) message(codec Codec, id RawMessage) *RPCMessage {
+	body := w.body.Bytes()
+	if codec.Kind(body) == RawObject {
+		msg := &RPCMessage{}
+		if codec.Unmarshal(body, msg) == nil && (msg.Result != nil || msg.Error != nil) {
+			return msg
+		}
+	}
+	code := ErrInternal
+	if w.status != 0 && w.status != http.StatusOK {
+		code = ErrorCode(w.status)
+	}
+	if len(body) == 0 {
+		return &RPCMessage{ID: id, Error: NewError(code, "empty response")}
+	}
+	return &RPCMessage{ID: id, Error: NewError(code, string(body))}
+}
+
+// readBatch split the batch body into raw elements
+func (r *rpcContext) readBatch(body []byte) error {
+	raws, err := r.Codec().SplitArray(body)
+	if err != nil {
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+		return err
+	}
+	if len(raws) == 0 {
+		err := fmt.Errorf("empty batch request")
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+		return err
+	}
+	if size := r.Server().Option().MaxBatchSize; size > 0 && int64(len(body)) > size {
+		err := fmt.Errorf("batch body too large (%d>%d)", len(body), size)
+		r.StopWriteStringStatus(http.StatusRequestEntityTooLarge, err.Error())
+		return err
+	}
+	if limit := r.Server().Option().BatchLimit; limit > 0 && len(raws) > limit {
+		err := fmt.Errorf("batch too large (%d>%d)", len(raws), limit)
+		r.StopWriteStringStatus(http.StatusRequestEntityTooLarge, err.Error())
+		return err
+	}
+	r.Lock()
+	r.batch = raws
+	r.Unlock()
+	return nil
+}
+
+func (r *rpcContext) batchMessages() []RawMessage {
+	r.RLock()
+	defer r.RUnlock()
+	return r.batch
+}
+
+// newBufferedContext create a buffered context sharing the request of r
+func (r *rpcContext) newBufferedContext(ctx context.Context, raw RawMessage, msg *RPCMessage) (*rpcContext, *responseRecorder) {
+	w := &responseRecorder{header: make(http.Header)}
+	c := newRpcContext(ctx, w, r.req)
+	c.buffered = true
+	c.codec = r.Codec()
+	r.RLock()
+	for k, v := range r.store {
+		c.store[k] = v
+	}
+	r.RUnlock()
+	c.store[BodyContextKey] = []byte(raw)
+	c.SetMsg(msg)
+	return c, w
+}
+
+// handleBatch run every element of the batch through the whole handler chain
+// and write the responses as an array in the order of the request
+func (s *server) handleBatch(ctx *rpcContext, raws []RawMessage) {
+	responses := make([]*RPCMessage, len(raws))
+	concurrency := s.option.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	codec := ctx.Codec()
+	for i, raw := range raws {
+		msg := &RPCMessage{}
+		err := codec.Unmarshal(raw, msg)
+		if err == nil {
+			err = msg.prepare(codec)
+		}
+		if err != nil {
+			responses[i] = &RPCMessage{ID: nullID(codec), Version: "2.0", Error: NewError(ErrInvalidRequest, err.Error())}
+			continue
+		}
+		if msg.IsNotification() && s.option.NotificationExecutor != nil {
+			s.dispatchNotification(ctx, raw, msg)
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, raw RawMessage, msg *RPCMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			responses[i] = s.serveBatchElement(ctx, raw, msg)
+		}(i, raw, msg)
+	}
+	wg.Wait()
+	list := make([]*RPCMessage, 0, len(responses))
+	for _, response := range responses {
+		if response != nil {
+			list = append(list, response)
+		}
+	}
+	if len(list) == 0 {
+		ctx.writeNoContent()
+		return
+	}
+	data, err := codec.Marshal(list)
+	if err != nil {
+		ctx.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.writeData(data)
+}
+
+func (s *server) serveBatchElement(ctx *rpcContext, raw RawMessage, msg *RPCMessage) *RPCMessage {
+	c, w := ctx.newBufferedContext(ctx.Context, raw, msg)
+	s.Handler(c)
+	if msg.IsNotification() {
+		return nil
+	}
+	c.RLock()
+	response := c.response
+	c.RUnlock()
+	if response == nil {
+		response = w.message(c.Codec(), msg.ID)
+	}
+	if response.ID == nil {
+		response.ID = msg.ID
+	}
+	return response
+}
@@ -0,0 +1,186 @@
+package j2rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+)
+
+// CacheStatusHeader report whether the response is served from the cache, HIT or MISS
+const CacheStatusHeader = "X-J2rpc-Cache"
+
+// ResponseCacheStore store the cached responses,
+// mdb.CacheStore (FreeStore, CCStore or RedisStore) satisfies it
+type ResponseCacheStore interface {
+	IdempotencyStore
+	DropPrefix(prefix ...string)
+}
+
+// ResponseCacheOption is the option of ResponseCache
+type ResponseCacheOption struct {
+	// Prefix is the prefix of the store keys, default "j2rpc-cache:"
+	Prefix string
+	// Scope return the scope of the cached responses, e.g. the user id, they are shared when it is nil.
+	// The methods guarded by permissions, group middlewares or the root middlewares other than the ones of j2rpc,
+	// e.g. s.Use("", jwtAuth), aren't cached without it
+	Scope func(c Context) string
+}
+
+type ResponseCacheOptionFunc func(option *ResponseCacheOption)
+
+// ResponseCache return the middleware caching the responses of the methods declaring WithCache,
+// keyed by method, canonicalized params and scope. Only the successful responses are cached.
+// The cache is looked up after the authorization and the argument validation of the call.
+// When a method declaring WithInvalidate succeeds, the cached responses of the tags are dropped,
+// e.g. s.Use("", ResponseCache(mdb.GetCCacheStore()))
+func ResponseCache(store ResponseCacheStore, opts ...ResponseCacheOptionFunc) Handler {
+	option := &ResponseCacheOption{Prefix: "j2rpc-cache:"}
+	for _, opt := range opts {
+		opt(option)
+	}
+	return func(c Context) {
+		passNeutral(c)
+		s, ok := c.Server().(*server)
+		msg := c.Msg()
+		if _, dispatched := callBegin(c); !ok || !dispatched || msg.IsNotification() {
+			c.Next()
+			return
+		}
+		f, has := s.router.funcs[msg.Method]
+		if !has || (f.meta.CacheTTL <= 0 && len(f.meta.Invalidates) == 0) {
+			c.Next()
+			return
+		}
+		if f.meta.CacheTTL <= 0 {
+			c.Next()
+			if response := c.Msg(); c.Wrote() && response.Error == nil {
+				store.DropPrefix(s.cachePrefixes(option.Prefix, f.meta.Invalidates)...)
+			}
+			return
+		}
+		if option.Scope == nil && s.guarded(msg.Method) {
+			c.Next()
+			return
+		}
+		key, err := cacheKey(c, option, msg)
+		if err != nil {
+			c.Next()
+			return
+		}
+		hit, guarded := false, false
+		addBeforeCall(c, func(c Context) bool {
+			// the root middlewares have run, the ones other than the middlewares of j2rpc may depend on the caller
+			if guarded = option.Scope == nil && s.rootGuarded(c); guarded {
+				return false
+			}
+			data, has := store.Get(key)
+			if !has {
+				c.Writer().Header().Set(CacheStatusHeader, "MISS")
+				return false
+			}
+			hit = true
+			c.Writer().Header().Set(CacheStatusHeader, "HIT")
+			c.WriteResponse(RawMessage(data))
+			return true
+		})
+		c.Next()
+		if response := c.Msg(); !hit && !guarded && c.Wrote() && response.Error == nil && response.Result != nil {
+			store.Set(key, response.Result, int64(math.Ceil(f.meta.CacheTTL.Seconds())))
+		}
+	}
+}
+
+// WithResponseCachePrefix set the prefix of the store keys
+func WithResponseCachePrefix(prefix string) ResponseCacheOptionFunc {
+	return func(option *ResponseCacheOption) {
+		option.Prefix = prefix
+	}
+}
+
+// WithResponseCacheScope cache the responses by the scope, e.g. the user id
+func WithResponseCacheScope(scope func(c Context) string) ResponseCacheOptionFunc {
+	return func(option *ResponseCacheOption) {
+		option.Scope = scope
+	}
+}
+
+// guarded report whether the method is protected by permissions or the middlewares of its groups,
+// so its response may depend on the caller
+func (s *server) guarded(method string) bool {
+	if len(s.router.funcs[method].meta.Permissions) > 0 {
+		return true
+	}
+	for key, group := range s.groups {
+		switch {
+		case key == method && len(group.Handlers()) > 1:
+			return true
+		case key != "" && strings.HasPrefix(method, key+Separator) && len(group.Handlers()) > 0:
+			return true
+		}
+	}
+	return false
+}
+
+// rootGuarded report whether the root group has the middlewares other than handleReadBody
+// and the middlewares of j2rpc the call passed, e.g. an auth middleware
+func (s *server) rootGuarded(c Context) bool {
+	return len(s.groups[""].Handlers())-1 > neutralPassed(c)
+}
+
+// cachePrefixes return the key prefixes of the cached methods with any of the tags or method names
+func (s *server) cachePrefixes(prefix string, tags []string) []string {
+	prefixes := make([]string, 0)
+	for name, f := range s.router.funcs {
+		if f.meta.CacheTTL <= 0 {
+			continue
+		}
+		if slices.Contains(tags, name) || slices.ContainsFunc(f.meta.CacheTags, func(tag string) bool {
+			return slices.Contains(tags, tag)
+		}) {
+			prefixes = append(prefixes, prefix+name+":")
+		}
+	}
+	return prefixes
+}
+
+// cacheKey return the key "<prefix><method>:<content type>:<scope>:<sha256 of the canonical params>"
+func cacheKey(c Context, option *ResponseCacheOption, msg *RPCMessage) (string, error) {
+	sum, err := paramsHash(c.Codec(), msg)
+	if err != nil {
+		return "", err
+	}
+	scope := ""
+	if option.Scope != nil {
+		scope = option.Scope(c)
+	}
+	return option.Prefix + msg.Method + ":" + c.Codec().ContentType() + ":" + scope + ":" + sum, nil
+}
+
+// paramsHash return the hex sha256 of the params canonicalized by decoding and encoding them as json
+// with the sorted object keys, the json numbers are kept as they are sent, so the large integers aren't rounded
+func paramsHash(codec Codec, msg *RPCMessage) (string, error) {
+	var params interface{}
+	if codec.Kind(msg.Params) != RawNull {
+		var err error
+		if _, ok := codec.(JSONCodec); ok {
+			dec := json.NewDecoder(bytes.NewReader(msg.Params))
+			dec.UseNumber()
+			err = dec.Decode(&params)
+		} else {
+			err = codec.Unmarshal(msg.Params, &params)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	canonical, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,162 @@
+package j2rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testCounter struct{ n int }
+
+func (c *testCounter) Get(int) int { return c.n }
+
+func (c *testCounter) Incr() int {
+	c.n++
+	return c.n
+}
+
+func TestResponseCache(t *testing.T) {
+	s := NewServer()
+	s.Use("", ResponseCache(&testStore{}))
+	s.RegisterTypeBus(&struct {
+		Counter *testCounter `j2rpc:"name:counter,cache:Get=1m|count,invalidate:Incr=count"`
+	}{Counter: &testCounter{}})
+	call := func(body string) (string, string) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return string(msg.Result), w.Header().Get(CacheStatusHeader)
+	}
+	for _, tc := range []struct{ body, result, status string }{
+		{`{"id":1,"method":"counter.get","params":[1]}`, "0", "MISS"},
+		{`{"id":2,"method":"counter.get","params":[ 1 ]}`, "0", "HIT"},
+		{`{"id":3,"method":"counter.get","params":[2]}`, "0", "MISS"},
+		{`{"id":4,"method":"counter.incr"}`, "1", ""},
+		{`{"id":5,"method":"counter.get","params":[1]}`, "1", "MISS"},
+		{`{"id":6,"method":"counter.get","params":[1]}`, "1", "HIT"},
+	} {
+		if result, status := call(tc.body); result != tc.result || status != tc.status {
+			t.Fatalf("%s: result=%s status=%q want %s %q", tc.body, result, status, tc.result, tc.status)
+		}
+	}
+}
+
+func TestResponseCache_Authorize(t *testing.T) {
+	authorizer := func(c Context, method string, required []string) error {
+		if c.Request().Header.Get("X-Role") != "admin" {
+			return NewError(ErrForbidden, "permission denied")
+		}
+		return nil
+	}
+	newServer := func(opts ...ResponseCacheOptionFunc) Server {
+		s := NewServer(WithAuthorizer(authorizer))
+		s.Use("", ResponseCache(&testStore{}, opts...))
+		s.RegisterTypeBus(&struct {
+			Counter *testCounter `j2rpc:"name:counter,cache:Get=1m,perms:Get=admin"`
+		}{Counter: &testCounter{n: 7}})
+		return s
+	}
+	call := func(s Server, role string) (*RPCMessage, string) {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"counter.get","params":[1]}`))
+		req.Header.Set("X-Role", role)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg, w.Header().Get(CacheStatusHeader)
+	}
+
+	s := newServer(WithResponseCacheScope(func(Context) string { return "" }))
+	for _, status := range []string{"MISS", "HIT"} {
+		if msg, got := call(s, "admin"); string(msg.Result) != "7" || got != status {
+			t.Fatalf("admin: result=%s status=%q want 7 %q", msg.Result, got, status)
+		}
+	}
+	if msg, got := call(s, "guest"); msg.Error == nil || msg.Error.Code != ErrForbidden || msg.Result != nil || got != "" {
+		t.Fatalf("guest got the cached response: %+v status=%q", msg, got)
+	}
+
+	// the guarded method isn't cached without the scope
+	s = newServer()
+	for i := 0; i < 2; i++ {
+		if msg, got := call(s, "admin"); string(msg.Result) != "7" || got != "" {
+			t.Fatalf("unscoped: result=%s status=%q", msg.Result, got)
+		}
+	}
+}
+
+func TestParamsHash(t *testing.T) {
+	hash := func(codec Codec, params interface{}) string {
+		t.Helper()
+		raw, ok := params.(string)
+		data := []byte(raw)
+		if !ok {
+			var err error
+			if data, err = codec.Marshal(params); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sum, err := paramsHash(codec, &RPCMessage{Params: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	cases := []struct {
+		name  string
+		codec Codec
+		a, b  interface{}
+		equal bool
+	}{
+		{"json large integers", JSONCodec{}, "[9007199254740993]", "[9007199254740992]", false},
+		{"json spaces and key order", JSONCodec{}, `{"a":1,"b":[2]}`, `{ "b": [2], "a": 1 }`, true},
+		{"msgpack large integers", MsgpackCodec{}, []int64{9007199254740993}, []int64{9007199254740992}, false},
+		{"msgpack key order", MsgpackCodec{}, map[string]int{"a": 1, "b": 2}, map[string]int{"b": 2, "a": 1}, true},
+	}
+	for _, v := range cases {
+		if equal := hash(v.codec, v.a) == hash(v.codec, v.b); equal != v.equal {
+			t.Errorf("%s: equal=%v want %v", v.name, equal, v.equal)
+		}
+	}
+}
+
+func TestResponseCache_RootMiddleware(t *testing.T) {
+	auth := func(c Context) {
+		if c.Request().Header.Get("X-Role") == "" {
+			c.WriteResponse(NewError(ErrAuthorization, "missing role"))
+			return
+		}
+		c.Next()
+	}
+	cases := []struct {
+		name     string
+		handlers []Handler
+		statuses []string
+	}{
+		{"the middlewares of j2rpc", []Handler{Recover(), Tracing(nil), ResponseCache(&testStore{})}, []string{"MISS", "HIT"}},
+		{"auth middleware", []Handler{auth, ResponseCache(&testStore{})}, []string{"", ""}},
+		{"auth middleware after the cache", []Handler{ResponseCache(&testStore{}), auth}, []string{"", ""}},
+	}
+	for _, v := range cases {
+		s := NewServer()
+		s.Use("", v.handlers...)
+		s.RegisterTypeBus(&struct {
+			Counter *testCounter `j2rpc:"name:counter,cache:Get=1m"`
+		}{Counter: &testCounter{n: 7}})
+		for _, status := range v.statuses {
+			req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"counter.get","params":[1]}`))
+			req.Header.Set("X-Role", "admin")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			if got := w.Header().Get(CacheStatusHeader); got != status || !strings.Contains(w.Body.String(), `"result":7`) {
+				t.Fatalf("%s: %s status=%q want %q", v.name, w.Body.String(), got, status)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+type clientHeaderKey struct{}
+
+// BatchElem is a call of the batch, Error is set when the call fails.
+// The call is sent as a notification when Notify is true.
+type BatchElem struct {
+	Method string
+	Args   []interface{}
+	Result interface{}
+	Error  error
+	Notify bool
+}
+
+// Client is the json-rpc client of j2rpc servers
+type Client struct {
+	transport Transport
+	codec     Codec
+	header    http.Header
+	id        uint64
+	crypto    *clientCrypto
+}
+
+// ClientOption configure the client
+type ClientOption func(c *Client)
+
+// NamedParams wrap the value which is sent as the named (object) params
+type NamedParams struct{ Value interface{} }
+
+// BatchCall send all the calls in a single request
+func (c *Client) BatchCall(ctx context.Context, elems []BatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+	msgs := make([]*RPCMessage, 0, len(elems))
+	index := make(map[string]int, len(elems))
+	for i, elem := range elems {
+		msg, err := c.newMessage(elem.Method, elem.Notify, elem.Args...)
+		if err != nil {
+			return err
+		}
+		if !elem.Notify {
+			index[string(msg.ID)] = i
+		}
+		msgs = append(msgs, msg)
+	}
+	body, err := c.codec.Marshal(msgs)
+	if err != nil {
+		return err
+	}
+	data, err := c.roundTrip(ctx, body)
+	if err != nil || len(index) == 0 {
+		return err
+	}
+	var responses []*RPCMessage
+	if err = c.codec.Unmarshal(data, &responses); err != nil {
+		return fmt.Errorf("invalid batch response: %s", err.Error())
+	}
+	for _, response := range responses {
+		i, has := index[string(response.ID)]
+		if !has {
+			continue
+		}
+		delete(index, string(response.ID))
+		elems[i].Error = response.decodeResult(c.codec, elems[i].Result)
+	}
+	for _, i := range index {
+		elems[i].Error = errors.New("missing response")
+	}
+	return nil
+}
+
+// Call invoke the method and decode the result into result, result can be nil.
+// An error response is returned as *Error with the same code.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, args ...interface{}) error {
+	msg, err := c.newMessage(method, false, args...)
+	if err != nil {
+		return err
+	}
+	body, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data, err := c.roundTrip(ctx, body)
+	if err != nil {
+		return err
+	}
+	response := &RPCMessage{}
+	if err = c.codec.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("invalid response: %s", err.Error())
+	}
+	return response.decodeResult(c.codec, result)
+}
+
+// Notify send a notification, the server doesn't respond to it
+func (c *Client) Notify(ctx context.Context, method string, args ...interface{}) error {
+	msg, err := c.newMessage(method, true, args...)
+	if err != nil {
+		return err
+	}
+	body, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = c.roundTrip(ctx, body)
+	return err
+}
+
+func (c *Client) newMessage(method string, notify bool, args ...interface{}) (*RPCMessage, error) {
+	msg := &RPCMessage{Version: "2.0", Method: method}
+	if !notify {
+		id, err := c.codec.Marshal(atomic.AddUint64(&c.id, 1))
+		if err != nil {
+			return nil, err
+		}
+		msg.ID = id
+	}
+	var params interface{} = args
+	if len(args) == 1 {
+		if named, ok := args[0].(NamedParams); ok {
+			params = named.Value
+		}
+	}
+	if len(args) > 0 {
+		data, err := c.codec.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid params: %s", err.Error())
+		}
+		msg.Params = data
+	}
+	return msg, nil
+}
+
+func (c *Client) roundTrip(ctx context.Context, body []byte) ([]byte, error) {
+	header := c.header.Clone()
+	header.Set("Content-Type", c.codec.ContentType())
+	if h, ok := ctx.Value(clientHeaderKey{}).(http.Header); ok {
+		for k, v := range h {
+			header[k] = v
+		}
+	}
+	setTimeoutHeader(ctx, header)
+	setTraceParentHeader(ctx, header)
+	if c.crypto != nil {
+		return c.crypto.roundTrip(header, body, func(header http.Header, body []byte) ([]byte, error) {
+			return c.transport.RoundTrip(ctx, header, body)
+		})
+	}
+	return c.transport.RoundTrip(ctx, header, body)
+}
+
+// decodeResult return the error of the response or decode the result
+func (r *RPCMessage) decodeResult(codec Codec, result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return codec.Unmarshal(r.Result, result)
+}
+
+// ContextWithHeader attach the http header to the calls made with the context
+func ContextWithHeader(ctx context.Context, key, value string) context.Context {
+	header := http.Header{}
+	if h, ok := ctx.Value(clientHeaderKey{}).(http.Header); ok {
+		header = h.Clone()
+	}
+	header.Set(key, value)
+	return context.WithValue(ctx, clientHeaderKey{}, header)
+}
+
+// NewClient create a client with the transport, e.g. NewHTTPTransport or NewServerTransport
+func NewClient(transport Transport, opts ...ClientOption) *Client {
+	c := &Client{transport: transport, codec: JSONCodec{}, header: http.Header{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithClientCodec set the codec of the calls, e.g. MsgpackCodec, the server must have it too
+func WithClientCodec(codec Codec) ClientOption {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
+
+// WithClientHeader set the http header sent with every call
+func WithClientHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
@@ -0,0 +1,58 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	s := newTestServer(WithCodec(MsgpackCodec{}))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	transports := map[string]Transport{
+		"server": NewServerTransport(s),
+		"http":   NewHTTPTransport(ts.URL),
+	}
+	codecs := map[string]Codec{"json": JSONCodec{}, "msgpack": MsgpackCodec{}}
+	for name, transport := range transports {
+		for codecName, codec := range codecs {
+			testClient(t, name+"/"+codecName, NewClient(transport, WithClientCodec(codec)))
+		}
+	}
+}
+
+func testClient(t *testing.T, name string, c *Client) {
+	ctx := context.Background()
+	var sum int
+	if err := c.Call(ctx, "arith.add", &sum, 1, 2); err != nil || sum != 3 {
+		t.Fatalf("%s: sum=%d err=%v", name, sum, err)
+	}
+	if err := c.Call(ctx, "arith.sub", &sum, NamedParams{map[string]int{"a": 5, "b": 2}}); err != nil || sum != 3 {
+		t.Fatalf("%s: sub=%d err=%v", name, sum, err)
+	}
+	var rpcErr *Error
+	if err := c.Call(ctx, "arith.fail", nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrForbidden {
+		t.Fatalf("%s: err=%v want code %d", name, err, ErrForbidden)
+	}
+	if err := c.Notify(ctx, "arith.add", 1, 1); err != nil {
+		t.Fatalf("%s: notify err=%v", name, err)
+	}
+	var a, b int
+	batch := []BatchElem{
+		{Method: "arith.add", Args: []interface{}{1, 1}, Result: &a},
+		{Method: "arith.add", Args: []interface{}{2, 2}, Notify: true},
+		{Method: "arith.none"},
+		{Method: "arith.add", Args: []interface{}{3, 3}, Result: &b},
+	}
+	if err := c.BatchCall(ctx, batch); err != nil {
+		t.Fatalf("%s: batch err=%v", name, err)
+	}
+	if a != 2 || b != 6 || batch[0].Error != nil || batch[3].Error != nil {
+		t.Fatalf("%s: a=%d b=%d batch=%+v", name, a, b, batch)
+	}
+	if !errors.As(batch[2].Error, &rpcErr) || rpcErr.Code != ErrNoMethod {
+		t.Fatalf("%s: err=%v want code %d", name, batch[2].Error, ErrNoMethod)
+	}
+}
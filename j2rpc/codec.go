@@ -0,0 +1,106 @@
+package j2rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+// RawKind is the kind of a raw value
+type RawKind int
+
+const (
+	RawNull RawKind = iota
+	RawArray
+	RawObject
+	RawValue
+)
+
+// Codec encode and decode the messages of a content type,
+// the RawMessage values are kept in the encoding of the codec
+type Codec interface {
+	// ContentType return the media type of the codec, e.g. application/json
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// Kind return the kind of the raw value, an empty value is RawNull
+	Kind(raw RawMessage) RawKind
+	// SplitArray split the raw array into the raw elements
+	SplitArray(raw RawMessage) ([]RawMessage, error)
+	// SplitObject split the raw object into the raw fields
+	SplitObject(raw RawMessage) (map[string]RawMessage, error)
+}
+
+// JSONCodec is the default codec
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+func (JSONCodec) Kind(raw RawMessage) RawKind {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return RawNull
+	case raw[0] == '[':
+		return RawArray
+	case raw[0] == '{':
+		return RawObject
+	default:
+		return RawValue
+	}
+}
+
+// Marshal encode v without escaping html and without the trailing newline
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := JSONEncode(v)
+	return bytes.TrimSuffix(data, []byte{'\n'}), err
+}
+
+func (JSONCodec) SplitArray(raw RawMessage) ([]RawMessage, error) {
+	var list []RawMessage
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+func (JSONCodec) SplitObject(raw RawMessage) (map[string]RawMessage, error) {
+	fields := make(map[string]RawMessage)
+	err := json.Unmarshal(raw, &fields)
+	return fields, err
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return JSONDecode(data, v) }
+
+// Codec return the codec of the request, JSONCodec is used when it isn't set
+func (r *rpcContext) Codec() Codec {
+	r.RLock()
+	defer r.RUnlock()
+	if r.codec == nil {
+		return JSONCodec{}
+	}
+	return r.codec
+}
+
+// requestCodec select the codec by the Content-Type of the request,
+// JSONCodec is used for the unknown content types
+func requestCodec(r *http.Request, codecs []Codec) Codec {
+	if r == nil || len(codecs) == 0 {
+		return JSONCodec{}
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return JSONCodec{}
+	}
+	for _, codec := range codecs {
+		if t, _, e := mime.ParseMediaType(codec.ContentType()); e == nil && t == mediaType {
+			return codec
+		}
+	}
+	return JSONCodec{}
+}
+
+// nullID return the null id in the encoding of the codec
+func nullID(codec Codec) RawMessage {
+	id, _ := codec.Marshal(nil)
+	return id
+}
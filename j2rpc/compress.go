@@ -0,0 +1,130 @@
+package j2rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+
+	// defaultCompressMinSize is the min size of the compressed responses when the threshold isn't set
+	defaultCompressMinSize = 1024
+)
+
+// DefaultEncodings are the response encodings in the order of preference
+var DefaultEncodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+)
+
+// compressData compress the data with the encoding
+func compressData(encoding string, data []byte) ([]byte, error) {
+	if encoding == EncodingZstd {
+		zstdEncoderOnce.Do(func() { zstdEncoder, _ = zstd.NewWriter(nil) })
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	}
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(buf, brotli.DefaultCompression)
+	case EncodingGzip:
+		w = gzip.NewWriter(buf)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressBody return the streaming reader of the request body by its Content-Encoding
+func decompressBody(r *http.Request) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return r.Body, nil
+	case EncodingBrotli:
+		return io.NopCloser(brotli.NewReader(r.Body)), nil
+	case EncodingGzip:
+		return gzip.NewReader(r.Body)
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// negotiateEncoding pick the first of the encodings accepted by the Accept-Encoding header
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, v := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(v, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if key, val, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(key) == "q" {
+			q, _ = strconv.ParseFloat(strings.TrimSpace(val), 64)
+		}
+		accepted[name] = q > 0
+	}
+	for _, encoding := range encodings {
+		if ok, has := accepted[encoding]; has {
+			if ok {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressResponse compress the response data when the client accepts one of the encodings
+// and the data reaches the threshold, it returns the data to write
+func (r *rpcContext) compressResponse(data []byte) []byte {
+	option := r.Server().Option()
+	if len(option.CompressEncodings) == 0 {
+		return data
+	}
+	header := r.Writer().Header()
+	header.Add("Vary", "Accept-Encoding")
+	if len(data) < option.CompressMinSize || header.Get("Content-Encoding") != "" {
+		return data
+	}
+	encoding := negotiateEncoding(r.req.Header.Get("Accept-Encoding"), option.CompressEncodings)
+	if encoding == "" {
+		return data
+	}
+	compressed, err := compressData(encoding, data)
+	if err != nil || len(compressed) >= len(data) {
+		return data
+	}
+	header.Set("Content-Encoding", encoding)
+	return compressed
+}
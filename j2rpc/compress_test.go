@@ -0,0 +1,104 @@
+package j2rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestServer_Compression(t *testing.T) {
+	s := NewServer(WithCompression(100))
+	s.RegisterFunc("list", func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = "item"
+		}
+		return list
+	})
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		EncodingGzip:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		EncodingBrotli: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		EncodingZstd:   func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for _, tc := range []struct {
+		accept, encoding string
+		n                int
+	}{
+		{"gzip", EncodingGzip, 100},
+		{"gzip, br;q=0.5", EncodingBrotli, 100},
+		{"gzip, zstd", EncodingZstd, 100},
+		{"*;q=1, zstd;q=0", EncodingBrotli, 100},
+		{"gzip", "", 2},
+		{"", "", 100},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"list","params":[`+strconv.Itoa(tc.n)+`]}`))
+		req.Header.Set("Accept-Encoding", tc.accept)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Fatalf("accept=%q: encoding=%q want %q", tc.accept, got, tc.encoding)
+		}
+		var r io.Reader = w.Body
+		if tc.encoding != "" {
+			var err error
+			if r, err = decoders[tc.encoding](w.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		msg := &RPCMessage{}
+		data, _ := io.ReadAll(r)
+		if err := JSONDecode(data, msg); err != nil || msg.Error != nil {
+			t.Fatalf("accept=%q: err=%v msg=%s", tc.accept, err, data)
+		}
+	}
+}
+
+func TestServer_RequestDecompression(t *testing.T) {
+	s := newTestServer()
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	_, _ = gw.Write([]byte(`{"id":1,"method":"arith.add","params":[1,2]}`))
+	_ = gw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/rpc", buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	msg := &RPCMessage{}
+	if err := JSONDecode(w.Body.Bytes(), msg); err != nil || string(msg.Result) != "3" {
+		t.Fatalf("err=%v body=%s", err, w.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader("x"))
+	req.Header.Set("Content-Encoding", "compress")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("code=%d", w.Code)
+	}
+}
+
+func TestServer_SizeLimits(t *testing.T) {
+	single := `{"id":1,"method":"arith.add","params":[1,2]}`
+	batch := "[" + single + "," + single + "]"
+	s := newTestServer(WithMaxRequestSize(int64(len(single))), WithMaxBatchSize(int64(len(batch))))
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{single, http.StatusOK},
+		{batch, http.StatusOK},
+		{`{"id":1,"method":"arith.add","params":[10,2]}`, http.StatusRequestEntityTooLarge},
+		{"[" + single + "," + single + "," + single + "]", http.StatusRequestEntityTooLarge},
+	} {
+		if w := doRequest(s, tc.body); w.Code != tc.code {
+			t.Fatalf("%s: code=%d want %d", tc.body, w.Code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package j2rpc
+
+import (
+	"context"
+	"sync"
+)
+
+type connContextKey struct{}
+
+// Conn is a long-lived client connection, e.g. websocket,
+// the server can push notifications to it
+type Conn interface {
+	// ID return the unique id of the connection
+	ID() string
+	// Context return the context which is canceled when the connection is closed
+	Context() context.Context
+	// Notify push a notification to the client
+	Notify(method string, params interface{}) error
+	// Close close the connection
+	Close() error
+}
+
+// ConnFromContext return the connection of the call,
+// it is false when the call isn't made over a long-lived connection
+func ConnFromContext(ctx context.Context) (Conn, bool) {
+	conn, ok := ctx.Value(connContextKey{}).(Conn)
+	return conn, ok
+}
+
+// Topics is the set of connections grouped by topic,
+// it is used to push notifications to many connections
+type Topics struct {
+	mu     sync.RWMutex
+	topics map[string]map[string]Conn
+}
+
+// Join add the connection to the topic, it leaves the topic when it is closed
+func (t *Topics) Join(topic string, conn Conn) {
+	t.mu.Lock()
+	if t.topics == nil {
+		t.topics = make(map[string]map[string]Conn)
+	}
+	conns, has := t.topics[topic]
+	if !has {
+		conns = make(map[string]Conn)
+		t.topics[topic] = conns
+	}
+	_, joined := conns[conn.ID()]
+	conns[conn.ID()] = conn
+	t.mu.Unlock()
+	if !joined {
+		go func() {
+			<-conn.Context().Done()
+			t.Leave(topic, conn)
+		}()
+	}
+}
+
+// Leave remove the connection from the topic
+func (t *Topics) Leave(topic string, conn Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns, has := t.topics[topic]
+	if !has {
+		return
+	}
+	delete(conns, conn.ID())
+	if len(conns) == 0 {
+		delete(t.topics, topic)
+	}
+}
+
+// Publish push the notification to every connection of the topic,
+// it returns the number of connections notified successfully
+func (t *Topics) Publish(topic, method string, params interface{}) int {
+	t.mu.RLock()
+	conns := make([]Conn, 0, len(t.topics[topic]))
+	for _, conn := range t.topics[topic] {
+		conns = append(conns, conn)
+	}
+	t.mu.RUnlock()
+	n := 0
+	for _, conn := range conns {
+		if conn.Notify(method, params) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// newNotification encode the server-initiated notification
+func newNotification(method string, params interface{}) ([]byte, error) {
+	msg := &RPCMessage{Version: "2.0", Method: method}
+	if params != nil {
+		data, err := JSONEncode(params)
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = data
+	}
+	return JSONEncode(msg)
+}
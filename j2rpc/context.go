@@ -3,10 +3,11 @@ package j2rpc
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -29,6 +30,13 @@ type rpcContext struct {
 	abort    bool
 	wrote    bool
 	server   Server
+	codec    Codec
+	// batch holds the raw elements when the request body is a JSON-RPC batch
+	batch []RawMessage
+	// buffered contexts keep the response message instead of writing it,
+	// they are used for the elements of a batch request
+	buffered bool
+	response *RPCMessage
 }
 
 // Abort ...
@@ -75,12 +83,13 @@ func (r *rpcContext) Msg() *RPCMessage {
 		return &RPCMessage{ID: RawMessage{'0'}}
 	}
 	return &RPCMessage{
-		ID:      msg.ID,
-		Version: msg.Version,
-		Method:  msg.Method,
-		Params:  msg.Params,
-		Result:  msg.Result,
-		Error:   msg.Error,
+		ID:             msg.ID,
+		Version:        msg.Version,
+		Method:         msg.Method,
+		Params:         msg.Params,
+		Result:         msg.Result,
+		Error:          msg.Error,
+		IdempotencyKey: msg.IdempotencyKey,
 	}
 }
 
@@ -112,32 +121,54 @@ func (r *rpcContext) ReadBody() (err error) {
 	if _msg != nil {
 		return
 	}
-	status, err := getValidateRequestStatus(r.req)
+	option := r.Server().Option()
+	limit := option.MaxRequestSize
+	if limit > 0 && option.MaxBatchSize > limit {
+		limit = option.MaxBatchSize
+	}
+	status, err := getValidateRequestStatus(r.req, limit, option.AllowGet)
 	if err != nil {
 		r.StopWriteStringStatus(status, err.Error())
 		return
 	}
+	if isGetRequest(r.req) {
+		err = r.readGetRequest()
+		return
+	}
 	if r.req.Body == nil {
 		r.StopWriteStringStatus(http.StatusBadRequest, "missing request body")
 		return
 	}
 	defer func() { _ = r.req.Body.Close() }()
-	body, err := io.ReadAll(r.req.Body)
+	reader, err := decompressBody(r.req)
+	if err != nil {
+		r.StopWriteStringStatus(http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	defer func() { _ = reader.Close() }()
+	if limit > 0 {
+		reader = io.NopCloser(io.LimitReader(reader, limit+1))
+	}
+	body, err := io.ReadAll(reader)
 	if err != nil {
 		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
 		return
 	}
+	if limit > 0 && int64(len(body)) > limit {
+		r.StopWriteStringStatus(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body too large (>%d)", limit))
+		return
+	}
 	//if we need reuse body, set body to req.Body
 	//r.req.Body = io.NopCloser(io.NewSectionReader(bytes.NewReader(body), 0, int64(len(body))))
-	if callerAfterReadBody := r.Server().Option().CallerAfterReadBody; callerAfterReadBody != nil {
+	if callerAfterReadBody := option.CallerAfterReadBody; callerAfterReadBody != nil {
 		if body, err = callerAfterReadBody(bytes.TrimSpace(body)); err != nil {
-			r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+			r.StopWriteStringStatus(bodyErrorStatus(err), err.Error())
 			return
 		}
 	}
-	if prepareRequestBody := r.Server().Option().PrepareRequestBody; prepareRequestBody != nil {
+	if prepareRequestBody := option.PrepareRequestBody; prepareRequestBody != nil {
 		if body, err = prepareRequestBody(r.req, bytes.TrimSpace(body)); err != nil {
-			r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+			r.StopWriteStringStatus(bodyErrorStatus(err), err.Error())
 			return
 		}
 	}
@@ -146,21 +177,28 @@ func (r *rpcContext) ReadBody() (err error) {
 		return
 	}
 	r.SetValue(BodyContextKey, body)
-	msg := &RPCMessage{}
-	if err = JSONDecode(body, msg); err != nil {
-		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+	codec := requestCodec(r.req, option.Codecs)
+	r.Lock()
+	r.codec = codec
+	r.Unlock()
+	if codec.Kind(body) == RawArray {
+		err = r.readBatch(body)
 		return
 	}
-	if !msg.hasValidID() {
-		r.StopWriteStringStatus(http.StatusBadRequest, "invalid request id")
+	if size := option.MaxRequestSize; size > 0 && int64(len(body)) > size {
+		err = fmt.Errorf("request body too large (%d>%d)", len(body), size)
+		r.StopWriteStringStatus(http.StatusRequestEntityTooLarge, err.Error())
 		return
 	}
-	msg.Method = strings.TrimSpace(msg.Method)
-	if msg.Method == "" {
-		r.StopWriteStringStatus(http.StatusBadRequest, "missing method")
+	msg := &RPCMessage{}
+	if err = codec.Unmarshal(body, msg); err != nil {
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = msg.prepare(codec); err != nil {
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
 		return
 	}
-	msg.FormatMethod()
 	r.SetMsg(msg)
 	return
 }
@@ -172,12 +210,13 @@ func (r *rpcContext) Server() Server { return r.server }
 func (r *rpcContext) SetMsg(msg *RPCMessage) {
 	r.Lock()
 	r.msg = &RPCMessage{
-		ID:      msg.ID,
-		Version: msg.Version,
-		Method:  msg.Method,
-		Params:  msg.Params,
-		Result:  msg.Result,
-		Error:   msg.Error,
+		ID:             msg.ID,
+		Version:        msg.Version,
+		Method:         msg.Method,
+		Params:         msg.Params,
+		Result:         msg.Result,
+		Error:          msg.Error,
+		IdempotencyKey: msg.IdempotencyKey,
 	}
 	r.Unlock()
 }
@@ -229,7 +268,7 @@ func (r *rpcContext) WriteResponse(args ...interface{}) {
 		}
 	}
 	if msg.Error == nil && msg.Result == nil {
-		ret, _ := JSONEncode("Success")
+		ret, _ := r.Codec().Marshal("Success")
 		msg.Result = ret
 	}
 	if msg.Error != nil {
@@ -238,20 +277,62 @@ func (r *rpcContext) WriteResponse(args ...interface{}) {
 	r.SetMsg(msg)
 	msg.Method = ""
 	msg.Params = nil
-	data, err := JSONEncode(msg)
+	msg.IdempotencyKey = ""
+	if msg.IsNotification() {
+		r.writeNoContent()
+		return
+	}
+	if r.buffered {
+		r.Lock()
+		r.response = msg
+		r.wrote = true
+		r.Unlock()
+		return
+	}
+	data, err := r.Codec().Marshal(msg)
 	if err != nil {
 		r.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
 		return
 	}
+	r.writeData(data)
+}
+
+func (r *rpcContext) Wrote() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.wrote
+}
+
+// writeData write the encoded response body
+func (r *rpcContext) writeData(data []byte) {
+	var err error
 	if callerBeforeWrite := r.Server().Option().CallerBeforeWrite; callerBeforeWrite != nil {
 		if data, err = callerBeforeWrite(bytes.TrimSpace(data)); err != nil {
 			r.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
+	// the ETag is computed before the sealing of PrepareResponseBody, e.g. the crypto nonce
+	if isGetRequest(r.req) && r.writeGetHeaders(data) {
+		r.Writer().Header().Set("X-Content-Type-Options", "nosniff")
+		r.Writer().Header().Set("Content-Type", r.Codec().ContentType())
+		if prepareWriter := r.Server().Option().PrepareWriter; prepareWriter != nil {
+			prepareWriter(r.Writer())
+		}
+		r.Writer().WriteHeader(http.StatusNotModified)
+		r.SetWrote(true)
+		return
+	}
+	if prepareResponseBody := r.Server().Option().PrepareResponseBody; prepareResponseBody != nil {
+		if data, err = prepareResponseBody(r.Writer(), r.req, data); err != nil {
+			r.StopWriteStringStatus(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
 	r.Writer().Header().Set("X-Content-Type-Options", "nosniff")
 	r.Writer().Header().Set("X-Content-Length", strconv.Itoa(len(data)))
-	r.Writer().Header().Set("Content-Type", "application/json; charset=utf-8")
+	r.Writer().Header().Set("Content-Type", r.Codec().ContentType())
+	data = r.compressResponse(data)
 	if prepareWriter := r.Server().Option().PrepareWriter; prepareWriter != nil {
 		prepareWriter(r.Writer())
 	}
@@ -260,10 +341,27 @@ func (r *rpcContext) WriteResponse(args ...interface{}) {
 	r.SetWrote(true)
 }
 
-func (r *rpcContext) Wrote() bool {
-	r.RLock()
-	defer r.RUnlock()
-	return r.wrote
+// writeNoContent finish the response without body, it is used for notifications
+func (r *rpcContext) writeNoContent() {
+	if r.buffered {
+		r.SetWrote(true)
+		return
+	}
+	if prepareWriter := r.Server().Option().PrepareWriter; prepareWriter != nil {
+		prepareWriter(r.Writer())
+	}
+	r.Writer().WriteHeader(http.StatusNoContent)
+	r.SetWrote(true)
+}
+
+// bodyErrorStatus return the http status of the request body hooks error,
+// the code of *Error is used when it is a http error status
+func bodyErrorStatus(err error) int {
+	var e *Error
+	if errors.As(err, &e) && e.Code >= http.StatusBadRequest && e.Code < 600 {
+		return int(e.Code)
+	}
+	return http.StatusBadRequest
 }
 
 func NewContext(ctx context.Context, writer http.ResponseWriter, req *http.Request) Context {
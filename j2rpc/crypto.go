@@ -0,0 +1,386 @@
+package j2rpc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glibtools/libs/crypto"
+	"github.com/glibtools/libs/keylock"
+)
+
+// CryptoHeader is the header of the encrypted requests, the value is
+//
+//	<session id>.<unix timestamp>.<nonce>[.<session key encrypted by crypto.EEInc>]
+//
+// the session id is the first 16 bytes of sha256(session key) in hex, the nonce is 12 random bytes in base64url,
+// the session key is sent to open the session and omitted afterwards.
+// The request body is base64(AES-GCM(body)) with the nonce, the response body is base64(nonce|AES-GCM(body)),
+// the header without the session key is the additional data of both, the response has the header of the session id.
+const CryptoHeader = "X-Crypto"
+
+const cryptoNonceSize = 12
+
+var (
+	// ErrCryptoSession is responded with http status 401 when the session is unknown or expired,
+	// the client should open it again with the session key
+	ErrCryptoSession = NewError(ErrAuthorization, "unknown crypto session")
+	// ErrCryptoRequired is responded with http status 400 to the plaintext requests when the encryption is required
+	ErrCryptoRequired = NewError(ErrorCode(http.StatusBadRequest), "encryption required")
+)
+
+// CryptoStore store the session keys and the used nonces,
+// mdb.ItfStorageCache (memory or redis) satisfies it
+type CryptoStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte, ttl ...int64)
+}
+
+// CryptoOption is the option of WithCrypto
+type CryptoOption struct {
+	// SessionTTL is the seconds a session key is kept, default 24h
+	SessionTTL int64
+	// Window is the max difference between the request timestamp and the server time, default 5m
+	Window time.Duration
+	// Prefix is the prefix of the store keys, default "j2rpc-crypto:"
+	Prefix string
+	// Required reject the plaintext requests
+	Required bool
+}
+
+type CryptoOptionFunc func(option *CryptoOption)
+
+type cryptoHeader struct {
+	sid       string
+	timestamp int64
+	nonce     []byte
+	key       string
+	// aad is the header without the session key
+	aad string
+}
+
+type serverCrypto struct {
+	privateKey string
+	store      CryptoStore
+	option     *CryptoOption
+	locks      keylock.KeyLock
+}
+
+// open decrypt the request body
+func (sc *serverCrypto) open(r *http.Request, body []byte) ([]byte, error) {
+	value := r.Header.Get(CryptoHeader)
+	if value == "" {
+		if sc.option.Required {
+			return nil, ErrCryptoRequired
+		}
+		return body, nil
+	}
+	h, err := parseCryptoHeader(value)
+	if err != nil {
+		return nil, err
+	}
+	if d := time.Since(time.Unix(h.timestamp, 0)); d > sc.option.Window || d < -sc.option.Window {
+		return nil, errors.New("crypto timestamp out of window")
+	}
+	key, err := sc.sessionKey(h)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, h.nonce, ciphertext, []byte(h.aad))
+	if err != nil {
+		return nil, err
+	}
+	// the nonce is recorded after the body is authenticated, so a forged body doesn't burn it
+	if err = sc.useNonce(h); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// seal encrypt the response body of the encrypted request
+func (sc *serverCrypto) seal(w http.ResponseWriter, r *http.Request, data []byte) ([]byte, error) {
+	value := r.Header.Get(CryptoHeader)
+	if value == "" {
+		return data, nil
+	}
+	h, err := parseCryptoHeader(value)
+	if err != nil {
+		return nil, err
+	}
+	key, has := sc.store.Get(sc.option.Prefix + "session:" + h.sid)
+	if !has {
+		return nil, ErrCryptoSession
+	}
+	data, err = sealCrypto(key, data, h.aad)
+	if err != nil {
+		return nil, err
+	}
+	w.Header().Set(CryptoHeader, h.sid)
+	return data, nil
+}
+
+// sessionKey return the key of the session, the session is opened when the header has the key
+func (sc *serverCrypto) sessionKey(h *cryptoHeader) ([]byte, error) {
+	storeKey := sc.option.Prefix + "session:" + h.sid
+	if h.key == "" {
+		if key, has := sc.store.Get(storeKey); has {
+			return key, nil
+		}
+		return nil, ErrCryptoSession
+	}
+	plain, err := crypto.EEInc.Decrypt(h.key, sc.privateKey)
+	if err != nil {
+		return nil, errors.New("invalid crypto session key")
+	}
+	key, err := hex.DecodeString(plain)
+	if err != nil || len(key) != 32 || cryptoSessionID(key) != h.sid {
+		return nil, errors.New("invalid crypto session key")
+	}
+	sc.store.Set(storeKey, key, sc.option.SessionTTL)
+	return key, nil
+}
+
+// useNonce reject the nonce used in the window, the check is serialized in the process only
+func (sc *serverCrypto) useNonce(h *cryptoHeader) error {
+	storeKey := sc.option.Prefix + "nonce:" + h.sid + ":" + base64.RawURLEncoding.EncodeToString(h.nonce)
+	unlock := sc.locks.Lock(storeKey)
+	defer unlock()
+	if _, has := sc.store.Get(storeKey); has {
+		return errors.New("crypto nonce replayed")
+	}
+	sc.store.Set(storeKey, []byte{1}, int64(2*sc.option.Window/time.Second)+1)
+	return nil
+}
+
+type clientCrypto struct {
+	mu        sync.Mutex
+	publicKey string
+	key       []byte
+	sid       string
+	// sealedKey is the session key encrypted by the public key, it is sent until the session is opened
+	sealedKey string
+	opened    bool
+}
+
+// header return the crypto header of a request
+func (cc *clientCrypto) header() (*cryptoHeader, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.key == nil {
+		cc.key = make([]byte, 32)
+		if _, err := rand.Read(cc.key); err != nil {
+			return nil, err
+		}
+		cc.sid = cryptoSessionID(cc.key)
+	}
+	h := &cryptoHeader{sid: cc.sid, timestamp: time.Now().Unix(), nonce: make([]byte, cryptoNonceSize)}
+	if _, err := rand.Read(h.nonce); err != nil {
+		return nil, err
+	}
+	if !cc.opened {
+		if cc.sealedKey == "" {
+			sealed, err := crypto.EEInc.Encrypt(hex.EncodeToString(cc.key), cc.publicKey)
+			if err != nil {
+				return nil, err
+			}
+			cc.sealedKey = sealed
+		}
+		h.key = cc.sealedKey
+	}
+	h.aad = h.sid + "." + strconv.FormatInt(h.timestamp, 10) + "." + base64.RawURLEncoding.EncodeToString(h.nonce)
+	return h, nil
+}
+
+// roundTrip encrypt the body and decrypt the response, the session is opened again when the server lost it
+func (cc *clientCrypto) roundTrip(header http.Header, body []byte, send func(header http.Header, body []byte) ([]byte, error)) ([]byte, error) {
+	for retry := true; ; retry = false {
+		h, err := cc.header()
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := newGCM(cc.key)
+		if err != nil {
+			return nil, err
+		}
+		value := h.aad
+		if h.key != "" {
+			value += "." + h.key
+		}
+		header.Set(CryptoHeader, value)
+		sealed := base64.StdEncoding.EncodeToString(gcm.Seal(nil, h.nonce, body, []byte(h.aad)))
+		data, err := send(header, []byte(sealed))
+		var e *Error
+		if errors.As(err, &e) && e.Code == ErrCryptoSession.Code && e.Message == ErrCryptoSession.Message && h.key == "" && retry {
+			cc.mu.Lock()
+			cc.opened = false
+			cc.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		cc.mu.Lock()
+		cc.opened = true
+		cc.mu.Unlock()
+		if len(data) == 0 {
+			return data, nil
+		}
+		return openCrypto(cc.key, data, h.aad)
+	}
+}
+
+// WithClientCrypto encrypt the calls with the session key exchanged by the public key of the server,
+// the server must have WithCrypto with the private key
+func WithClientCrypto(publicKey string) ClientOption {
+	return func(c *Client) {
+		c.crypto = &clientCrypto{publicKey: publicKey}
+	}
+}
+
+// WithCrypto decrypt the request bodies and encrypt the responses of the requests with the CryptoHeader,
+// the session keys are exchanged by crypto.EEInc with the private key, e.g. crypto.EEInc.GenerateKeyPair().
+// The params of the GET requests are sealed as the body, e.g. GET /rpc/user.info?params=<sealed params>.
+// The request is opened after the PrepareRequestBody set before it, and the response is sealed
+// after the CallerBeforeWrite and before the PrepareResponseBody set before it.
+// The encrypted sessions are http only, the websocket messages are plaintext and rejected by WithCryptoRequired,
+// serve the websocket over wss instead.
+func WithCrypto(privateKey string, store CryptoStore, opts ...CryptoOptionFunc) Option {
+	option := &CryptoOption{SessionTTL: 24 * 3600, Window: 5 * time.Minute, Prefix: "j2rpc-crypto:"}
+	for _, opt := range opts {
+		opt(option)
+	}
+	sc := &serverCrypto{privateKey: privateKey, store: store, option: option}
+	return func(o *ServerOption) {
+		prepareRequestBody, prepareResponseBody := o.PrepareRequestBody, o.PrepareResponseBody
+		o.PrepareRequestBody = func(r *http.Request, body []byte) ([]byte, error) {
+			if prepareRequestBody != nil {
+				var err error
+				if body, err = prepareRequestBody(r, body); err != nil {
+					return nil, err
+				}
+			}
+			return sc.open(r, body)
+		}
+		o.PrepareResponseBody = func(w http.ResponseWriter, r *http.Request, data []byte) ([]byte, error) {
+			data, err := sc.seal(w, r, data)
+			if err != nil || prepareResponseBody == nil {
+				return data, err
+			}
+			return prepareResponseBody(w, r, data)
+		}
+	}
+}
+
+// WithCryptoPrefix set the prefix of the store keys
+func WithCryptoPrefix(prefix string) CryptoOptionFunc {
+	return func(option *CryptoOption) {
+		option.Prefix = prefix
+	}
+}
+
+// WithCryptoRequired reject the plaintext requests
+func WithCryptoRequired() CryptoOptionFunc {
+	return func(option *CryptoOption) {
+		option.Required = true
+	}
+}
+
+// WithCryptoSessionTTL set the seconds a session key is kept
+func WithCryptoSessionTTL(ttl int64) CryptoOptionFunc {
+	return func(option *CryptoOption) {
+		if ttl > 0 {
+			option.SessionTTL = ttl
+		}
+	}
+}
+
+// WithCryptoWindow set the max difference between the request timestamp and the server time
+func WithCryptoWindow(d time.Duration) CryptoOptionFunc {
+	return func(option *CryptoOption) {
+		if d > 0 {
+			option.Window = d
+		}
+	}
+}
+
+func cryptoSessionID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// openCrypto decrypt base64(nonce|ciphertext)
+func openCrypto(key, data []byte, aad string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < cryptoNonceSize {
+		return nil, errors.New("invalid encrypted data")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, raw[:cryptoNonceSize], raw[cryptoNonceSize:], []byte(aad))
+}
+
+func parseCryptoHeader(value string) (*cryptoHeader, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, errors.New("invalid crypto header")
+	}
+	timestamp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid crypto timestamp")
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(nonce) != cryptoNonceSize {
+		return nil, errors.New("invalid crypto nonce")
+	}
+	h := &cryptoHeader{sid: parts[0], timestamp: timestamp, nonce: nonce, aad: strings.Join(parts[:3], ".")}
+	if len(parts) == 4 {
+		h.key = parts[3]
+	}
+	return h, nil
+}
+
+// sealCrypto encrypt the data into base64(nonce|ciphertext)
+func sealCrypto(key, data []byte, aad string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, cryptoNonceSize, cryptoNonceSize+len(data)+gcm.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, data, []byte(aad))
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/glibtools/libs/crypto"
+)
+
+// recordTransport keep the last request sent by the transport
+type recordTransport struct {
+	Transport
+	header http.Header
+	body   []byte
+}
+
+func (t *recordTransport) RoundTrip(ctx context.Context, header http.Header, body []byte) ([]byte, error) {
+	t.header, t.body = header.Clone(), body
+	return t.Transport.RoundTrip(ctx, header, body)
+}
+
+func TestServer_Crypto(t *testing.T) {
+	pair, err := crypto.EEInc.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &testStore{}
+	s := newTestServer(WithCrypto(pair.PrivateKey, store, WithCryptoRequired()))
+	transport := &recordTransport{Transport: NewServerTransport(s)}
+	c := NewClient(transport, WithClientCrypto(pair.PublicKey))
+	for i := 0; i < 2; i++ {
+		var sum int
+		if err = c.Call(context.Background(), "arith.add", &sum, 1, 2); err != nil || sum != 3 {
+			t.Fatalf("sum=%d err=%v", sum, err)
+		}
+		if parts := strings.Split(transport.header.Get(CryptoHeader), "."); (i == 0) != (len(parts) == 4) {
+			t.Fatalf("call %d: crypto header=%v", i, parts)
+		}
+		if bytes.Contains(transport.body, []byte("arith.add")) {
+			t.Fatalf("plaintext body %s", transport.body)
+		}
+	}
+	send := func(header http.Header, body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", bytes.NewReader(body))
+		req.Header = header
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := send(transport.header, transport.body); code != http.StatusBadRequest {
+		t.Fatalf("replay code=%d", code)
+	}
+	if code := send(http.Header{}, []byte(`{"id":1,"method":"arith.add","params":[1,2]}`)); code != http.StatusBadRequest {
+		t.Fatalf("plaintext code=%d", code)
+	}
+	// a forged body doesn't burn the nonce of the sealed one
+	var sealedHeader http.Header
+	var sealedBody []byte
+	cc := &clientCrypto{publicKey: pair.PublicKey}
+	_, _ = cc.roundTrip(http.Header{}, []byte(`{"id":1,"method":"arith.add","params":[3,4]}`), func(header http.Header, body []byte) ([]byte, error) {
+		sealedHeader, sealedBody = header.Clone(), body
+		return nil, errors.New("not sent")
+	})
+	ciphertext, err := base64.StdEncoding.DecodeString(string(sealedBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[0] ^= 1
+	if code := send(sealedHeader.Clone(), []byte(base64.StdEncoding.EncodeToString(ciphertext))); code != http.StatusBadRequest {
+		t.Fatalf("forged code=%d", code)
+	}
+	if code := send(sealedHeader, sealedBody); code != http.StatusOK {
+		t.Fatalf("sealed code=%d after the forged one", code)
+	}
+	// the client opens the session again when the server lost it
+	store.Clear()
+	var sum int
+	if err = c.Call(context.Background(), "arith.add", &sum, 2, 2); err != nil || sum != 4 {
+		t.Fatalf("sum=%d err=%v", sum, err)
+	}
+}
+
+func TestServer_CryptoGet(t *testing.T) {
+	pair, err := crypto.EEInc.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(WithAllowGet(), WithCrypto(pair.PrivateKey, &testStore{}, WithCryptoRequired()))
+	s.RegisterFunc("secret", func(n int) int { return n * 7 }, WithSafe())
+	get := func(header http.Header, params string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/rpc/secret?params="+url.QueryEscape(params), nil)
+		if header != nil {
+			req.Header = header
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+	if w := get(nil, "[6]"); w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "42") {
+		t.Fatalf("plaintext GET: code=%d body=%s", w.Code, w.Body.String())
+	}
+
+	cc := &clientCrypto{publicKey: pair.PublicKey}
+	data, err := cc.roundTrip(http.Header{}, []byte("[6]"), func(header http.Header, body []byte) ([]byte, error) {
+		w := get(header, string(body))
+		if w.Code != http.StatusOK {
+			return nil, fmt.Errorf("code=%d body=%s", w.Code, w.Body.String())
+		}
+		return w.Body.Bytes(), nil
+	})
+	msg := &RPCMessage{}
+	if err == nil {
+		err = JSONDecode(data, msg)
+	}
+	if err != nil || string(msg.Result) != "42" {
+		t.Fatalf("sealed GET: result=%s err=%v", msg.Result, err)
+	}
+}
+
+func TestParseCryptoHeader(t *testing.T) {
+	for _, value := range []string{"", "sid.1", "sid.x.AAAAAAAAAAAAAAAA", "sid.1.short", "a.1.AAAAAAAAAAAAAAAA.key.more"} {
+		if _, err := parseCryptoHeader(value); err == nil {
+			t.Fatalf("%q should be invalid", value)
+		}
+	}
+	h, err := parseCryptoHeader("sid.1.AAAAAAAAAAAAAAAA.key")
+	if err != nil || h.aad != "sid.1.AAAAAAAAAAAAAAAA" || h.key != "key" || len(h.nonce) != cryptoNonceSize {
+		t.Fatalf("h=%+v err=%v", h, err)
+	}
+}
@@ -0,0 +1,109 @@
+package j2rpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeoutHeader is the header of the timeout requested by the client,
+// the value is a duration like "1500ms" or a number of milliseconds
+const TimeoutHeader = "X-J2rpc-Timeout"
+
+// deadlineContext is the Context of a method with timeout,
+// its context.Context methods are of the deadline
+type deadlineContext struct {
+	Context
+	deadline context.Context
+}
+
+func (d *deadlineContext) Deadline() (time.Time, bool) { return d.deadline.Deadline() }
+
+func (d *deadlineContext) Done() <-chan struct{} { return d.deadline.Done() }
+
+func (d *deadlineContext) Err() error { return d.deadline.Err() }
+
+func (d *deadlineContext) GetContext() context.Context { return d.deadline }
+
+func (d *deadlineContext) Value(key any) any { return d.deadline.Value(key) }
+
+type callResult struct {
+	results []reflect.Value
+	panic   interface{}
+}
+
+// methodTimeout return the timeout of the call, it is the smaller one of the method timeout
+// and the client timeout which is capped by ServerOption.MaxTimeout
+func (s *server) methodTimeout(c Context, f funcInfo) time.Duration {
+	timeout := f.meta.Timeout
+	if s.option.MaxTimeout <= 0 || c.Request() == nil {
+		return timeout
+	}
+	requested := parseTimeoutHeader(c.Request().Header.Get(TimeoutHeader))
+	if requested <= 0 {
+		return timeout
+	}
+	requested = min(requested, s.option.MaxTimeout)
+	if timeout <= 0 || requested < timeout {
+		return requested
+	}
+	return timeout
+}
+
+// callWithDeadline call the method and return ErrTimeout when the deadline expires first,
+// the method keeps running in background and should honour the context.
+// A panic of the method is raised again in the caller goroutine.
+func callWithDeadline(deadline context.Context, fn reflect.Value, args []reflect.Value) ([]reflect.Value, error) {
+	done := make(chan callResult, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- callResult{panic: err}
+			}
+		}()
+		done <- callResult{results: fn.Call(args)}
+	}()
+	select {
+	case ret := <-done:
+		if ret.panic != nil {
+			panic(ret.panic)
+		}
+		return ret.results, nil
+	case <-deadline.Done():
+		go func() {
+			if ret := <-done; ret.panic != nil {
+				slog.Error("panic after timeout", slog.Any("panic", ret.panic))
+			}
+		}()
+		return nil, NewError(ErrTimeout, fmt.Sprintf("method timeout: %s", deadline.Err().Error()))
+	}
+}
+
+func parseTimeoutHeader(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond
+	}
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+// setTimeoutHeader send the remaining time of the context deadline to the server
+func setTimeoutHeader(ctx context.Context, header http.Header) {
+	if header.Get(TimeoutHeader) != "" {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline).Milliseconds(); remaining > 0 {
+			header.Set(TimeoutHeader, strconv.FormatInt(remaining, 10))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSleeper struct{}
+
+func (testSleeper) Sleep(ctx context.Context, ms int) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return true, nil
+	}
+}
+
+func TestServer_MethodTimeout(t *testing.T) {
+	s := NewServer()
+	s.RegisterTypeBus(&struct {
+		Sleeper *testSleeper `j2rpc:"name:sleeper,timeout:Sleep=20ms"`
+	}{})
+	msg := &RPCMessage{}
+	body := doRequest(s, `{"id":1,"method":"sleeper.sleep","params":[500]}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error == nil || msg.Error.Code != ErrTimeout {
+		t.Fatalf("error=%v want code %d", msg.Error, ErrTimeout)
+	}
+	msg = &RPCMessage{}
+	body = doRequest(s, `{"id":2,"method":"sleeper.sleep","params":[1]}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error != nil || string(msg.Result) != "true" {
+		t.Fatalf("result=%s error=%v", msg.Result, msg.Error)
+	}
+}
+
+func TestServer_TimeoutHeader(t *testing.T) {
+	s := NewServer(WithMaxTimeout(30 * time.Millisecond))
+	s.RegisterType(&testSleeper{}, "sleeper", OnMethod("Sleep", WithTimeout(time.Second)))
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"sleeper.sleep","params":[500]}`))
+	req.Header.Set(TimeoutHeader, "10s")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	s.ServeHTTP(w, req)
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("elapsed=%s, the header should be capped by the max timeout", elapsed)
+	}
+	msg := &RPCMessage{}
+	if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error == nil || msg.Error.Code != ErrTimeout {
+		t.Fatalf("error=%v want code %d", msg.Error, ErrTimeout)
+	}
+}
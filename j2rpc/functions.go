@@ -1,21 +1,21 @@
 package j2rpc
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
 )
 
-const maxRequestContentLength = 1 << 20 * 5
+const maxRequestContentLength int64 = 1 << 20 * 5
 
-func argsToNameInterface(args ...interface{}) (name string, bean interface{}) {
+func argsToNameInterface(args ...interface{}) (name string, bean interface{}, opts []MethodOption) {
 	for _, arg := range args {
 		switch v := arg.(type) {
 		case string:
 			name = v
+		case MethodOption:
+			opts = append(opts, v)
 		default:
 			bean = v
 		}
@@ -23,56 +23,114 @@ func argsToNameInterface(args ...interface{}) (name string, bean interface{}) {
 	return
 }
 
-func getValidateRequestStatus(r *http.Request) (int, error) {
-	if r.Method == http.MethodOptions {
+func getValidateRequestStatus(r *http.Request, limit int64, allowGet bool) (int, error) {
+	if r.Method == http.MethodOptions || (r.Method == http.MethodGet && allowGet) {
 		return 0, nil
 	}
 	if r.Method != http.MethodPost {
 		return http.StatusMethodNotAllowed, errors.New("method isn't allowed")
 	}
-	if r.ContentLength > maxRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
+	if limit > 0 && r.ContentLength > limit {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, limit)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	return 0, nil
 }
 
-func parseArgumentArray(dec *Decoder, types []reflect.Type) ([]reflect.Value, error) {
+// parseArguments parse the params by position or by name
+func parseArguments(codec Codec, rawArgs RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	switch codec.Kind(rawArgs) {
+	case RawObject:
+		return parseNamedArguments(codec, rawArgs, types, names)
+	case RawArray:
+		return parsePositionalArguments(codec, rawArgs, types)
+	case RawNull:
+		return parsePositionalArguments(codec, nil, types)
+	default:
+		return nil, errors.New("non-array args")
+	}
+}
+
+// parseNamedArguments parse object params, the object is decoded into the single struct argument
+// when the names aren't declared, otherwise its fields are matched to the names.
+func parseNamedArguments(codec Codec, rawArgs RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if len(names) == 0 {
+		if len(types) != 1 || !isObjectType(types[0]) {
+			return nil, errors.New("named args require a single struct argument or declared param names")
+		}
+		agv := reflect.New(types[0])
+		if err := codec.Unmarshal(rawArgs, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument 0: %s", err.Error())
+		}
+		return []reflect.Value{agv.Elem()}, nil
+	}
+	fields, err := codec.SplitObject(rawArgs)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+	for name := range fields {
+		if i, has := index[name]; !has || i >= len(types) {
+			return nil, fmt.Errorf("unknown argument %q", name)
+		}
+	}
 	args := make([]reflect.Value, 0, len(types))
-	for i := 0; dec.More(); i++ {
-		if i >= len(types) {
-			return args, fmt.Errorf("too many arguments, want at most %d", len(types))
+	for i, typ := range types {
+		raw, has := RawMessage(nil), false
+		if i < len(names) {
+			raw, has = fields[names[i]]
 		}
-		agv := reflect.New(types[i])
-		if err := dec.Decode(agv.Interface()); err != nil {
-			return args, fmt.Errorf("invalid argument %d: %s", i, err.Error())
+		if !has {
+			args = append(args, ZeroValue(typ))
+			continue
 		}
-		if agv.IsNil() && types[i].Kind() != reflect.Ptr {
-			return args, fmt.Errorf("missing value for required argument %d", i)
+		agv := reflect.New(typ)
+		if err = codec.Unmarshal(raw, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument %q: %s", names[i], err.Error())
 		}
 		args = append(args, agv.Elem())
 	}
-	_, err := dec.Token()
-	return args, err
+	return args, nil
 }
 
-func parsePositionalArguments(rawArgs RawMessage, types []reflect.Type) ([]reflect.Value, error) {
-	dec := NewDecoder(bytes.NewReader(rawArgs))
-	var args []reflect.Value
-	tok, err := dec.Token()
-	switch {
-	case err == io.EOF || (err == nil && tok == nil):
-	case err != nil:
-		return nil, err
-	case tok == Delim('['):
-		if args, err = parseArgumentArray(dec, types); err != nil {
+// parsePositionalArguments parse array params, the missing arguments are zero values,
+// null is rejected for the required arguments which can't be nil, e.g. int or struct
+func parsePositionalArguments(codec Codec, rawArgs RawMessage, types []reflect.Type) ([]reflect.Value, error) {
+	var elems []RawMessage
+	if rawArgs != nil {
+		var err error
+		if elems, err = codec.SplitArray(rawArgs); err != nil {
 			return nil, err
 		}
-	default:
-		return nil, errors.New("non-array args")
+	}
+	if len(elems) > len(types) {
+		return nil, fmt.Errorf("too many arguments, want at most %d", len(types))
+	}
+	args := make([]reflect.Value, 0, len(types))
+	for i, raw := range elems {
+		if codec.Kind(raw) == RawNull && !isNillable(types[i]) {
+			return nil, fmt.Errorf("missing value for required argument %d", i)
+		}
+		agv := reflect.New(types[i])
+		if err := codec.Unmarshal(raw, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument %d: %s", i, err.Error())
+		}
+		args = append(args, agv.Elem())
 	}
 	for i := len(args); i < len(types); i++ {
 		args = append(args, ZeroValue(types[i]))
 	}
 	return args, nil
 }
+
+// isNillable report whether the argument of the type accepts null
+func isNillable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
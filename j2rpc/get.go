@@ -0,0 +1,83 @@
+package j2rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetParamsQuery is the query parameter of the params of the GET requests, e.g.
+//
+//	GET /rpc/user.info?params=%5B1%5D
+const GetParamsQuery = "params"
+
+// isGetRequest report whether the request is the GET invocation of a safe method
+func isGetRequest(r *http.Request) bool { return r != nil && r.Method == http.MethodGet }
+
+// readGetRequest build the message of the GET request, the method is the last segment of the path
+// and the params are the json of the GetParamsQuery transformed by the PrepareRequestBody
+func (r *rpcContext) readGetRequest() error {
+	codec := Codec(JSONCodec{})
+	r.Lock()
+	r.codec = codec
+	r.Unlock()
+	path := strings.TrimRight(r.req.URL.Path, "/")
+	msg := &RPCMessage{
+		ID:      RawMessage{'0'},
+		Version: "2.0",
+		Method:  path[strings.LastIndex(path, "/")+1:],
+	}
+	params := []byte(r.req.URL.Query().Get(GetParamsQuery))
+	// the params are the body of the GET request, e.g. they are sealed by WithCrypto
+	if prepareRequestBody := r.Server().Option().PrepareRequestBody; prepareRequestBody != nil {
+		var err error
+		if params, err = prepareRequestBody(r.req, params); err != nil {
+			r.StopWriteStringStatus(bodyErrorStatus(err), err.Error())
+			return err
+		}
+	}
+	if len(params) > 0 {
+		msg.Params = RawMessage(params)
+	}
+	if err := msg.prepare(codec); err != nil {
+		r.StopWriteStringStatus(http.StatusBadRequest, err.Error())
+		return err
+	}
+	r.SetMsg(msg)
+	return nil
+}
+
+// writeGetHeaders set the ETag and Cache-Control of the GET response computed from the plaintext data,
+// it reports whether the cached response of the client is still valid.
+// The responses are private unless the method is public and has no permissions
+func (r *rpcContext) writeGetHeaders(data []byte) (notModified bool) {
+	header := r.Writer().Header()
+	header.Add("Vary", "Authorization")
+	msg := r.Msg()
+	if msg.Error != nil {
+		header.Set("Cache-Control", "no-store")
+		return false
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	header.Set("ETag", etag)
+	cacheControl := "no-cache"
+	if s, ok := r.Server().(*server); ok {
+		if f, has := s.router.funcs[msg.Method]; has && f.meta.CacheTTL > 0 {
+			scope := "private"
+			if f.meta.Public && len(f.meta.Permissions) == 0 {
+				scope = "public"
+			}
+			cacheControl = scope + ", max-age=" + strconv.Itoa(int(f.meta.CacheTTL.Seconds()))
+		}
+	}
+	header.Set("Cache-Control", cacheControl)
+	for _, v := range strings.Split(r.req.Header.Get("If-None-Match"), ",") {
+		if v = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(v), "W/")); v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package j2rpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_GetSealed(t *testing.T) {
+	var n int32
+	s := NewServer(WithAllowGet(), WithPrepareResponseBody(func(_ http.ResponseWriter, _ *http.Request, data []byte) ([]byte, error) {
+		return append(data, fmt.Sprintf(" %d", atomic.AddInt32(&n, 1))...), nil
+	}))
+	s.RegisterFunc("one", func() int { return 1 }, WithSafe())
+	etag := ""
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/rpc/one", nil)
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if i == 0 {
+			etag = w.Header().Get("ETag")
+		} else if w.Code != http.StatusNotModified {
+			t.Fatalf("the ETag changed with the sealed body: code=%d etag=%s", w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestServer_Get(t *testing.T) {
+	s := NewServer(WithAllowGet())
+	s.RegisterTypeBus(&struct {
+		Arith *testArith `j2rpc:"name:arith,safe:Add|Sum,cache:Add=1m"`
+	}{})
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+	w := get("/rpc/arith.add?params=" + url.QueryEscape("[1,2]"))
+	msg := &RPCMessage{}
+	if err := JSONDecode(w.Body.Bytes(), msg); err != nil || string(msg.Result) != "3" {
+		t.Fatalf("err=%v body=%s", err, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Cache-Control") != "private, max-age=60" || w.Header().Get("Vary") != "Authorization" {
+		t.Fatalf("header=%v", w.Header())
+	}
+	if w = get("/rpc/arith.add?params="+url.QueryEscape("[1,2]"), "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("code=%d", w.Code)
+	}
+	if w = get("/rpc/arith.sum/?params=" + url.QueryEscape(`{"A":1,"B":3}`)); w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("header=%v body=%s", w.Header(), w.Body.String())
+	}
+	if w = get("/rpc/arith.sub?params=" + url.QueryEscape("[3,1]")); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unsafe code=%d", w.Code)
+	}
+	s = NewServer(WithAllowGet())
+	s.RegisterFunc("add", func(a, b int) int { return a + b }, WithSafe(), WithPublic(), WithCache(time.Minute))
+	if w = get("/rpc/add?params=" + url.QueryEscape("[1,2]")); w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("public header=%v", w.Header())
+	}
+
+	s = NewServer()
+	s.RegisterFunc("now", time.Now, WithSafe())
+	if w = get("/rpc/now"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("disallowed code=%d", w.Code)
+	}
+}
@@ -12,3 +12,29 @@ var (
 )
 
 type Handler func(c Context)
+
+// beforeCallContextKey is the context key of the hooks run by the call handler after the authorization
+// and the argument validation, e.g. the cache lookup of ResponseCache
+const beforeCallContextKey = "___j2rpc.beforeCall"
+
+// beforeCallHook return true when it has written the response, the method isn't called then
+type beforeCallHook func(c Context) bool
+
+// addBeforeCall add the hook run before the method of c is called
+func addBeforeCall(c Context, hook beforeCallHook) {
+	v, _ := c.GetValue(beforeCallContextKey)
+	hooks, _ := v.([]beforeCallHook)
+	c.SetValue(beforeCallContextKey, append(hooks[:len(hooks):len(hooks)], hook))
+}
+
+// runBeforeCall run the hooks of c in order, it returns true when a hook has written the response
+func runBeforeCall(c Context) bool {
+	v, _ := c.GetValue(beforeCallContextKey)
+	hooks, _ := v.([]beforeCallHook)
+	for _, hook := range hooks {
+		if hook(c) {
+			return true
+		}
+	}
+	return false
+}
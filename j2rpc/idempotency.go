@@ -0,0 +1,178 @@
+package j2rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"github.com/glibtools/libs/keylock"
+)
+
+// IdempotencyKeyHeader is the header of the idempotency key,
+// the key can also be sent in the reserved field RPCMessage.IdempotencyKey
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyReplayedHeader is set on the responses replayed from the store
+const IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+// IdempotencyStore store the responses of the calls with idempotency key,
+// mdb.ItfStorageCache (memory or redis) satisfies it
+type IdempotencyStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte, ttl ...int64)
+}
+
+// IdempotencyOption is the option of Idempotency
+type IdempotencyOption struct {
+	// TTL is the seconds the response is replayed, default 24h
+	TTL int64
+	// Prefix is the prefix of the store keys, default "j2rpc-idempotency:"
+	Prefix string
+	// Methods are the methods honouring the idempotency key, all the methods when it is empty
+	Methods []string
+	// Scope return the scope of the keys, e.g. the user id, so the callers don't share the keys,
+	// default CredentialScope
+	Scope func(c Context) string
+}
+
+type IdempotencyOptionFunc func(option *IdempotencyOption)
+
+// idempotencyRecord is the stored response bound to the hash of the params
+type idempotencyRecord struct {
+	Params string     `json:"params"`
+	Result RawMessage `json:"result,omitempty"`
+	Error  *Error     `json:"error,omitempty"`
+}
+
+// Idempotency return the middleware which executes a call with idempotency key once and replays
+// the stored response to the repeats within the TTL, concurrent duplicates wait for the first call.
+// The keys are scoped by the Scope, the credential of the request by default, and bound to the params, a repeat with other params gets ErrBadParams.
+// The response is replayed after the authorization and the argument validation of the call,
+// the internal, server and timeout errors aren't stored, so the call can be retried.
+// The duplicates are serialized in the process only, e.g. s.Use("", Idempotency(mdb.GetCCacheStore()))
+func Idempotency(store IdempotencyStore, opts ...IdempotencyOptionFunc) Handler {
+	option := &IdempotencyOption{TTL: 24 * 3600, Prefix: "j2rpc-idempotency:"}
+	for _, opt := range opts {
+		opt(option)
+	}
+	if option.Scope == nil {
+		option.Scope = CredentialScope
+	}
+	locks := &keylock.KeyLock{}
+	return func(c Context) {
+		passNeutral(c)
+		msg := c.Msg()
+		key := idempotencyKey(c, msg)
+		if _, dispatched := callBegin(c); !dispatched || key == "" || msg.IsNotification() ||
+			(len(option.Methods) > 0 && !slices.Contains(option.Methods, msg.Method)) {
+			c.Next()
+			return
+		}
+		codec := c.Codec()
+		hash, err := paramsHash(codec, msg)
+		if err != nil {
+			c.Next()
+			return
+		}
+		storeKey := option.Prefix + codec.ContentType() + ":" + msg.Method + ":" + option.Scope(c) + ":" + key
+		unlock := locks.Lock(storeKey)
+		defer unlock()
+		// the response is stored only when the call passed the authorization and reached the hook
+		reached, replayed := false, false
+		addBeforeCall(c, func(c Context) bool {
+			reached = true
+			data, has := store.Get(storeKey)
+			if !has {
+				return false
+			}
+			stored := &idempotencyRecord{}
+			if codec.Unmarshal(data, stored) != nil {
+				return false
+			}
+			replayed = true
+			if stored.Params != hash {
+				c.WriteResponse(NewError(ErrBadParams, "the idempotency key is reused with different params"))
+				return true
+			}
+			c.Writer().Header().Set(IdempotencyReplayedHeader, "true")
+			if stored.Error != nil {
+				c.WriteResponse(stored.Error)
+			} else {
+				c.WriteResponse(stored.Result)
+			}
+			return true
+		})
+		c.Next()
+		response := c.Msg()
+		if !reached || replayed || !c.Wrote() || (response.Error == nil && response.Result == nil) {
+			return
+		}
+		if response.Error != nil {
+			switch response.Error.Code {
+			case ErrInternal, ErrServer, ErrTimeout:
+				return
+			}
+		}
+		data, err := codec.Marshal(&idempotencyRecord{Params: hash, Result: response.Result, Error: response.Error})
+		if err != nil {
+			return
+		}
+		store.Set(storeKey, data, option.TTL)
+	}
+}
+
+// CredentialScope return the hex sha256 of the credential of the request, the Authorization or Token header,
+// so the callers presenting different tokens don't share the keys, it is empty without the credential
+func CredentialScope(c Context) string {
+	if c.Request() == nil {
+		return ""
+	}
+	credential := c.Request().Header.Get("Authorization")
+	if credential == "" {
+		credential = c.Request().Header.Get("Token")
+	}
+	if credential == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithIdempotencyMethods set the methods honouring the idempotency key
+func WithIdempotencyMethods(methods ...string) IdempotencyOptionFunc {
+	return func(option *IdempotencyOption) {
+		option.Methods = methods
+	}
+}
+
+// WithIdempotencyScope scope the idempotency keys, e.g. by the user id
+func WithIdempotencyScope(scope func(c Context) string) IdempotencyOptionFunc {
+	return func(option *IdempotencyOption) {
+		option.Scope = scope
+	}
+}
+
+// WithIdempotencyTTL set the seconds the response is replayed
+func WithIdempotencyTTL(ttl int64) IdempotencyOptionFunc {
+	return func(option *IdempotencyOption) {
+		if ttl > 0 {
+			option.TTL = ttl
+		}
+	}
+}
+
+// idempotencyKey return the key of the message or the header,
+// the header key of a batch element is combined with the id of the element
+func idempotencyKey(c Context, msg *RPCMessage) string {
+	if msg.IdempotencyKey != "" {
+		return msg.IdempotencyKey
+	}
+	if c.Request() == nil {
+		return ""
+	}
+	key := c.Request().Header.Get(IdempotencyKeyHeader)
+	if rc, ok := c.(*rpcContext); ok && rc.buffered && key != "" {
+		key += ":" + string(msg.ID)
+	}
+	return key
+}
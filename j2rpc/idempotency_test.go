@@ -0,0 +1,167 @@
+package j2rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type testStore struct{ sync.Map }
+
+func (s *testStore) Get(key string) ([]byte, bool) {
+	v, ok := s.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+func (s *testStore) Set(key string, val []byte, _ ...int64) { s.Store(key, val) }
+
+func TestIdempotency(t *testing.T) {
+	var created int32
+	s := NewServer()
+	s.Use("", Idempotency(&testStore{}))
+	s.RegisterFunc("create", func() int32 { return atomic.AddInt32(&created, 1) })
+	call := func(key, body string) (*RPCMessage, http.Header) {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg, w.Header()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if msg, _ := call("k1", `{"id":1,"method":"create"}`); string(msg.Result) != "1" {
+				t.Errorf("result=%s want=1", msg.Result)
+			}
+		}()
+	}
+	wg.Wait()
+	msg, header := call("", `{"id":2,"method":"create","idempotencyKey":"k1"}`)
+	if string(msg.Result) != "1" || string(msg.ID) != "2" || header.Get(IdempotencyReplayedHeader) != "true" {
+		t.Fatalf("result=%s id=%s replayed=%q", msg.Result, msg.ID, header.Get(IdempotencyReplayedHeader))
+	}
+	if msg, _ = call("k2", `{"id":3,"method":"create"}`); string(msg.Result) != "2" {
+		t.Fatalf("result=%s want=2", msg.Result)
+	}
+	if msg, _ = call("", `{"id":4,"method":"create"}`); string(msg.Result) != "3" {
+		t.Fatalf("result=%s want=3", msg.Result)
+	}
+}
+
+func TestIdempotency_CredentialScope(t *testing.T) {
+	var created int32
+	s := NewServer()
+	s.Use("", Idempotency(&testStore{}))
+	s.RegisterFunc("create", func() int32 { return atomic.AddInt32(&created, 1) })
+	cases := []struct {
+		name   string
+		header string
+		token  string
+		result string
+	}{
+		{"first", "Authorization", "Bearer a", "1"},
+		{"replayed", "Authorization", "Bearer a", "1"},
+		{"other token", "Authorization", "Bearer b", "2"},
+		{"token header", "Token", "a", "3"},
+		{"no credential", "", "", "4"},
+		{"replayed without credential", "", "", "4"},
+	}
+	for _, v := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"create"}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		if v.header != "" {
+			req.Header.Set(v.header, v.token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Result) != v.result {
+			t.Fatalf("%s: result=%s want=%s", v.name, msg.Result, v.result)
+		}
+	}
+}
+
+type testOrders struct{ created int64 }
+
+func (o *testOrders) Create(n int64) int64 { return atomic.AddInt64(&o.created, n) }
+
+func TestIdempotency_Scope(t *testing.T) {
+	authorizer := func(c Context, method string, required []string) error {
+		if c.Request().Header.Get("X-User") == "guest" {
+			return NewError(ErrForbidden, "permission denied")
+		}
+		return nil
+	}
+	s := NewServer(WithAuthorizer(authorizer))
+	s.Use("", Idempotency(&testStore{}, WithIdempotencyScope(func(c Context) string {
+		return c.Request().Header.Get("X-User")
+	})))
+	s.RegisterTypeBus(&struct {
+		Orders *testOrders `j2rpc:"name:orders,perms:Create=user"`
+	}{Orders: &testOrders{}})
+	call := func(user, params string) *RPCMessage {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"id":1,"method":"orders.create","params":`+params+`}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	cases := []struct {
+		name   string
+		user   string
+		params string
+		result string
+		code   ErrorCode
+	}{
+		{"first", "u1", "[1]", "1", 0},
+		{"replayed", "u1", "[ 1 ]", "1", 0},
+		{"other scope", "u2", "[1]", "2", 0},
+		{"other params", "u1", "[2]", "", ErrBadParams},
+		{"large params", "u3", "[9007199254740992]", "9007199254740994", 0},
+		{"other large params", "u3", "[9007199254740993]", "", ErrBadParams},
+		{"forbidden", "guest", "[1]", "", ErrForbidden},
+	}
+	for _, v := range cases {
+		msg := call(v.user, v.params)
+		var code ErrorCode
+		if msg.Error != nil {
+			code = msg.Error.Code
+		}
+		if string(msg.Result) != v.result || code != v.code {
+			t.Fatalf("%s: result=%s error=%v want %s %d", v.name, msg.Result, msg.Error, v.result, v.code)
+		}
+	}
+}
+
+func (s *testStore) DropPrefix(prefix ...string) {
+	s.Range(func(key, _ any) bool {
+		for _, p := range prefix {
+			if strings.HasPrefix(key.(string), p) {
+				s.Delete(key)
+			}
+		}
+		return true
+	})
+}
@@ -9,6 +9,7 @@ type Context interface {
 	context.Context
 	Abort()
 	AddHandler(handlers ...Handler)
+	Codec() Codec
 	GetContext() context.Context
 	GetValue(key string) (interface{}, bool)
 	IsAbort() bool
@@ -29,8 +30,14 @@ type Group interface {
 }
 
 type Server interface {
+	Disable(method string, err ...error) error
+	Discover() *OpenRPCDocument
+	Enable(method string) error
+	Err() error
 	Handler(c Context)
+	Methods() []MethodInfo
 	Option() *ServerOption
+	Permissions() map[string][]string
 	RegisterFunc(args ...interface{})
 	RegisterType(args ...interface{})
 	RegisterTypeBus(bus interface{})
@@ -0,0 +1,165 @@
+// Package j2rpctest provides utilities for testing j2rpc servers in process, without a network listener.
+package j2rpctest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/glibtools/libs/j2rpc"
+)
+
+var id uint64
+
+// CallOption configure the request of the call
+type CallOption func(call *callConfig)
+
+type callConfig struct {
+	ctx    context.Context
+	header http.Header
+	values map[string]interface{}
+	notify bool
+}
+
+// AssertErrorCode fail the test when the response isn't an error with the code
+func AssertErrorCode(t testing.TB, msg *j2rpc.RPCMessage, code j2rpc.ErrorCode) {
+	t.Helper()
+	if msg == nil || msg.Error == nil {
+		t.Fatalf("j2rpctest: want error code %d, got no error", code)
+		return
+	}
+	if msg.Error.Code != code {
+		t.Fatalf("j2rpctest: want error code %d, got %d (%s)", code, msg.Error.Code, msg.Error.Message)
+	}
+}
+
+// AssertNoError fail the test when the response is an error
+func AssertNoError(t testing.TB, msg *j2rpc.RPCMessage) {
+	t.Helper()
+	if msg == nil {
+		t.Fatal("j2rpctest: nil response")
+		return
+	}
+	if msg.Error != nil {
+		t.Fatalf("j2rpctest: unexpected error %d (%s)", msg.Error.Code, msg.Error.Message)
+	}
+}
+
+// AssertResult fail the test when the result decoded into the type of want isn't equal to want
+func AssertResult(t testing.TB, msg *j2rpc.RPCMessage, want interface{}) {
+	t.Helper()
+	AssertNoError(t, msg)
+	got := reflect.New(reflect.TypeOf(want))
+	if err := DecodeResult(msg, got.Interface()); err != nil {
+		t.Fatalf("j2rpctest: decode result %s: %s", msg.Result, err.Error())
+		return
+	}
+	if !reflect.DeepEqual(got.Elem().Interface(), want) {
+		t.Fatalf("j2rpctest: result %s, want %#v", msg.Result, want)
+	}
+}
+
+// Call invoke the method through the whole handler chain of the server and return the response.
+// params is sent as it is, use a slice for the positional params and a struct or map for the named params.
+// The error is returned when the server doesn't respond a json-rpc message, e.g. a 400 status;
+// the error response of the method is in RPCMessage.Error.
+func Call(s j2rpc.Server, method string, params interface{}, opts ...CallOption) (*j2rpc.RPCMessage, error) {
+	call := &callConfig{ctx: context.Background(), header: http.Header{}, values: map[string]interface{}{}}
+	for _, opt := range opts {
+		opt(call)
+	}
+	msg := &j2rpc.RPCMessage{Version: "2.0", Method: method}
+	if !call.notify {
+		msg.ID = j2rpc.RawMessage(fmt.Sprint(atomic.AddUint64(&id, 1)))
+	}
+	if params != nil {
+		data, err := j2rpc.JSONEncode(params)
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = bytes.TrimSpace(data)
+	}
+	body, err := j2rpc.JSONEncode(msg)
+	if err != nil {
+		return nil, err
+	}
+	ctx := call.ctx
+	for k, v := range call.values {
+		ctx = context.WithValue(ctx, k, v)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range call.header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	c := j2rpc.NewContext(ctx, w, req)
+	for k, v := range call.values {
+		c.SetValue(k, v)
+	}
+	s.Handler(c)
+	switch w.Code {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, j2rpc.NewError(j2rpc.ErrorCode(w.Code), string(bytes.TrimSpace(w.Body.Bytes())))
+	}
+	response := &j2rpc.RPCMessage{}
+	if err = j2rpc.JSONDecode(w.Body.Bytes(), response); err != nil {
+		return nil, fmt.Errorf("invalid response %q: %s", w.Body.String(), err.Error())
+	}
+	return response, nil
+}
+
+// DecodeResult decode the result of the response into v, the error response is returned as *j2rpc.Error
+func DecodeResult(msg *j2rpc.RPCMessage, v interface{}) error {
+	if msg.Error != nil {
+		return msg.Error
+	}
+	return j2rpc.JSONDecode(msg.Result, v)
+}
+
+// MustCall is like Call but fails the test on the error
+func MustCall(t testing.TB, s j2rpc.Server, method string, params interface{}, opts ...CallOption) *j2rpc.RPCMessage {
+	t.Helper()
+	msg, err := Call(s, method, params, opts...)
+	if err != nil {
+		t.Fatalf("j2rpctest: call %s: %s", method, err.Error())
+	}
+	return msg
+}
+
+// AsNotification send the call as a notification, Call returns a nil message
+func AsNotification() CallOption {
+	return func(call *callConfig) {
+		call.notify = true
+	}
+}
+
+// WithContext set the context of the request
+func WithContext(ctx context.Context) CallOption {
+	return func(call *callConfig) {
+		call.ctx = ctx
+	}
+}
+
+// WithHeader set the http header of the request
+func WithHeader(key, value string) CallOption {
+	return func(call *callConfig) {
+		call.header.Set(key, value)
+	}
+}
+
+// WithValue inject the value into the j2rpc context store (Context.GetValue) and the request context (Value),
+// e.g. a fake user for the auth middleware
+func WithValue(key string, val interface{}) CallOption {
+	return func(call *callConfig) {
+		call.values[key] = val
+	}
+}
@@ -0,0 +1,52 @@
+package j2rpctest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glibtools/libs/j2rpc"
+)
+
+type user struct{ Name string }
+
+func newServer() j2rpc.Server {
+	s := j2rpc.NewServer()
+	s.Use("admin", func(c j2rpc.Context) {
+		if _, ok := c.GetValue("user"); !ok {
+			c.WriteResponse(j2rpc.NewError(j2rpc.ErrAuthorization, "login required"))
+		}
+		c.Next()
+	})
+	s.RegisterFunc("add", func(a, b int) int { return a + b })
+	s.RegisterFunc("admin.whoami", func(ctx context.Context) string { return ctx.Value("user").(*user).Name })
+	s.RegisterFunc("fail", func() error { return errors.New("boom") })
+	return s
+}
+
+func TestCall(t *testing.T) {
+	s := newServer()
+	for _, tc := range []struct {
+		method string
+		params interface{}
+		opts   []CallOption
+		want   interface{}
+		code   j2rpc.ErrorCode
+	}{
+		{method: "add", params: []int{1, 2}, want: 3},
+		{method: "admin.whoami", code: j2rpc.ErrAuthorization},
+		{method: "admin.whoami", opts: []CallOption{WithValue("user", &user{Name: "tom"})}, want: "tom"},
+		{method: "fail", code: j2rpc.ErrInternal},
+		{method: "none", code: j2rpc.ErrNoMethod},
+	} {
+		msg := MustCall(t, s, tc.method, tc.params, tc.opts...)
+		if tc.code != 0 {
+			AssertErrorCode(t, msg, tc.code)
+			continue
+		}
+		AssertResult(t, msg, tc.want)
+	}
+	if msg, err := Call(s, "add", []int{1, 1}, AsNotification()); msg != nil || err != nil {
+		t.Fatalf("notification: msg=%v err=%v", msg, err)
+	}
+}
@@ -1,6 +1,7 @@
 package j2rpc
 
 import (
+	"errors"
 	"strings"
 )
 
@@ -11,11 +12,27 @@ const (
 	ErrBadParams      ErrorCode = -32602
 	ErrInternal       ErrorCode = -32603
 	ErrServer         ErrorCode = -32000
+	ErrTimeout        ErrorCode = -32001
+	ErrDisabled       ErrorCode = -32002
 
 	ErrAuthorization ErrorCode = 401
 	ErrForbidden     ErrorCode = 403
 )
 
+// errorCodeMessages is the default messages of the error codes
+var errorCodeMessages = map[ErrorCode]string{
+	ErrParse:          "Parse error",
+	ErrInvalidRequest: "Invalid request",
+	ErrNoMethod:       "Method not found",
+	ErrBadParams:      "Invalid params",
+	ErrInternal:       "Internal error",
+	ErrServer:         "Server error",
+	ErrTimeout:        "Request timeout",
+	ErrDisabled:       "Method disabled",
+	ErrAuthorization:  "Unauthorized",
+	ErrForbidden:      "Forbidden",
+}
+
 // Error ... Error codes
 type Error struct {
 	Code    ErrorCode   `json:"code"`
@@ -32,6 +49,9 @@ func (e *Error) ErrorData() interface{} { return e.Data }
 // ErrorCode ... Error codes
 type ErrorCode int
 
+// Message return the default message of the code
+func (c ErrorCode) Message() string { return errorCodeMessages[c] }
+
 type ItfJ2rpcError interface {
 	ErrorCode() int
 	Error() string
@@ -45,6 +65,8 @@ type RPCMessage struct {
 	Params  RawMessage `json:"params,omitempty"`
 	Result  RawMessage `json:"result,omitempty"`
 	Error   *Error     `json:"error,omitempty"`
+	// IdempotencyKey is the reserved field of the idempotency key, see Idempotency
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 // FormatMethod ...
@@ -63,7 +85,26 @@ func (r *RPCMessage) FormatMethod() {
 	r.Method = strings.Join(list, Separator)
 }
 
-func (r *RPCMessage) hasValidID() bool { return len(r.ID) > 0 && r.ID[0] != '{' && r.ID[0] != '[' }
+// IsNotification report whether the message is a notification, which is a request without id
+func (r *RPCMessage) IsNotification() bool { return len(r.ID) == 0 }
+
+func (r *RPCMessage) hasValidID(codec Codec) bool {
+	kind := codec.Kind(r.ID)
+	return len(r.ID) > 0 && kind != RawObject && kind != RawArray
+}
+
+// prepare validate the request message and format its method name
+func (r *RPCMessage) prepare(codec Codec) error {
+	if !r.IsNotification() && !r.hasValidID(codec) {
+		return errors.New("invalid request id")
+	}
+	r.Method = strings.TrimSpace(r.Method)
+	if r.Method == "" {
+		return errors.New("missing method")
+	}
+	r.FormatMethod()
+	return nil
+}
 
 // NewError ...
 func NewError(code ErrorCode, Msg string, data ...interface{}) *Error {
@@ -0,0 +1,227 @@
+package j2rpc
+
+import (
+	"strings"
+	"time"
+)
+
+// ImplRPCParamNames declare the parameter names of the type's methods,
+// they are used to bind named (object) params
+type ImplRPCParamNames interface {
+	RPCParamNames(methodName string) []string
+}
+
+// ImplRPCPermissions declare the required permissions of the type's methods,
+// they are checked by the Authorizer of the server
+type ImplRPCPermissions interface {
+	RPCPermissions(methodName string) []string
+}
+
+// MethodMeta is the metadata of a registered method
+type MethodMeta struct {
+	// Name is the rpc method name
+	Name string
+	// GoName is the name of the go function or method
+	GoName string
+	// ParamNames is the names of the params, the context argument is excluded
+	ParamNames []string
+	// Timeout is the deadline of the method call, zero means no timeout
+	Timeout time.Duration
+	// Permissions are the permissions required to call the method, any of them is enough
+	Permissions []string
+	// CacheTTL enable the response cache of the method, see ResponseCache
+	CacheTTL time.Duration
+	// CacheTags are the tags of the cached responses besides the method name
+	CacheTags []string
+	// Invalidates are the tags or method names whose cached responses are dropped when the method succeeds
+	Invalidates []string
+	// Safe mark the method read-only, it can be called by GET when ServerOption.AllowGet is set
+	Safe bool
+	// Public allow the shared caches to store the GET responses of the method without permissions,
+	// they are private by default
+	Public bool
+}
+
+// MethodOption configure a registered method,
+// it can be passed to RegisterFunc and RegisterType
+type MethodOption func(meta *MethodMeta)
+
+// OnMethod apply the options only to the method with the given go name or rpc name
+func OnMethod(name string, opts ...MethodOption) MethodOption {
+	return func(meta *MethodMeta) {
+		if meta.GoName != name && meta.Name != name {
+			return
+		}
+		for _, opt := range opts {
+			opt(meta)
+		}
+	}
+}
+
+// WithCache cache the responses of the method for ttl, the tags are used to invalidate them
+func WithCache(ttl time.Duration, tags ...string) MethodOption {
+	return func(meta *MethodMeta) {
+		meta.CacheTTL = ttl
+		meta.CacheTags = tags
+	}
+}
+
+// WithInvalidate drop the cached responses of the tags or method names when the method succeeds
+func WithInvalidate(tags ...string) MethodOption {
+	return func(meta *MethodMeta) {
+		meta.Invalidates = tags
+	}
+}
+
+// WithParamNames set the parameter names of the method
+func WithParamNames(names ...string) MethodOption {
+	return func(meta *MethodMeta) {
+		meta.ParamNames = names
+	}
+}
+
+// WithPermissions set the permissions required to call the method
+func WithPermissions(permissions ...string) MethodOption {
+	return func(meta *MethodMeta) {
+		meta.Permissions = permissions
+	}
+}
+
+// WithPublic allow the shared caches to store the GET responses of the method
+func WithPublic() MethodOption {
+	return func(meta *MethodMeta) {
+		meta.Public = true
+	}
+}
+
+// WithSafe mark the method read-only, so it can be called by GET
+func WithSafe() MethodOption {
+	return func(meta *MethodMeta) {
+		meta.Safe = true
+	}
+}
+
+// WithTimeout set the timeout of the method
+func WithTimeout(d time.Duration) MethodOption {
+	return func(meta *MethodMeta) {
+		meta.Timeout = d
+	}
+}
+
+// parseTypeTag parse the j2rpc tag of a bus field, e.g.
+//
+//	`j2rpc:"name:user,params:Login=username|password;Info=id,timeout:Login=3s;Info=1s,perms:Delete=admin|root"`
+//	`j2rpc:"name:user,cache:Info=30s|profile;List=1m,invalidate:Update=profile|user.list"`
+//	`j2rpc:"name:user,safe:Info|List,public:List"`
+//
+// a value without method name applies to all the methods, e.g. `j2rpc:"timeout:5s,perms:admin,safe:*"`
+func parseTypeTag(tag string) (name string, opts []MethodOption) {
+	for _, v := range strings.Split(tag, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		key, value, found := strings.Cut(v, ":")
+		if !found {
+			if name == "" {
+				name = v
+			}
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "name":
+			name = strings.TrimSpace(value)
+		case "params":
+			for method, val := range parseTagMethodValues(value) {
+				opts = append(opts, OnMethod(method, WithParamNames(splitTagList(val)...)))
+			}
+		case "timeout":
+			opts = append(opts, tagMethodOptions(value, func(val string) MethodOption {
+				d, err := time.ParseDuration(val)
+				if err != nil {
+					return nil
+				}
+				return WithTimeout(d)
+			})...)
+		case "cache":
+			opts = append(opts, tagMethodOptions(value, func(val string) MethodOption {
+				list := splitTagList(val)
+				if len(list) == 0 {
+					return nil
+				}
+				d, err := time.ParseDuration(list[0])
+				if err != nil {
+					return nil
+				}
+				return WithCache(d, list[1:]...)
+			})...)
+		case "invalidate":
+			opts = append(opts, tagMethodOptions(value, func(val string) MethodOption {
+				return WithInvalidate(splitTagList(val)...)
+			})...)
+		case "safe":
+			opts = append(opts, tagFlagOptions(value, WithSafe())...)
+		case "public":
+			opts = append(opts, tagFlagOptions(value, WithPublic())...)
+		case "perms":
+			opts = append(opts, tagMethodOptions(value, func(val string) MethodOption {
+				return WithPermissions(splitTagList(val)...)
+			})...)
+		}
+	}
+	return
+}
+
+// tagFlagOptions apply the option to the methods of the list like "Info|List", "*" means all the methods
+func tagFlagOptions(value string, opt MethodOption) []MethodOption {
+	opts := make([]MethodOption, 0)
+	for _, method := range splitTagList(value) {
+		if method == "*" {
+			opts = append(opts, opt)
+			continue
+		}
+		opts = append(opts, OnMethod(method, opt))
+	}
+	return opts
+}
+
+// tagMethodOptions create the options from the tag value, it is either a value for all the methods
+// or the values of the methods like "Login=3s;Info=1s"
+func tagMethodOptions(value string, newOption func(val string) MethodOption) []MethodOption {
+	opts := make([]MethodOption, 0)
+	if !strings.Contains(value, "=") {
+		if opt := newOption(strings.TrimSpace(value)); opt != nil {
+			opts = append(opts, opt)
+		}
+		return opts
+	}
+	for method, val := range parseTagMethodValues(value) {
+		if opt := newOption(val); opt != nil {
+			opts = append(opts, OnMethod(method, opt))
+		}
+	}
+	return opts
+}
+
+// parseTagMethodValues parse the value like "Login=username|password;Info=id"
+func parseTagMethodValues(value string) map[string]string {
+	values := make(map[string]string)
+	for _, item := range strings.Split(value, ";") {
+		method, val, found := strings.Cut(item, "=")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(method)] = strings.TrimSpace(val)
+	}
+	return values
+}
+
+func splitTagList(value string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
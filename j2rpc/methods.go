@@ -0,0 +1,103 @@
+package j2rpc
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMethodNotFound is returned by Disable and Enable when the method isn't registered
+	ErrMethodNotFound = errors.New("method not found")
+	// ErrDuplicateMethod is collected by the registrations of the existing methods in strict mode, see Server.Err
+	ErrDuplicateMethod = errors.New("duplicate method")
+)
+
+// MethodInfo describe a registered method, see Server.Methods
+type MethodInfo struct {
+	// Name is the rpc method name
+	Name string
+	// Func is the full name of the go function or method, e.g. github.com/x/api.(*User).Login
+	Func string
+	// ArgTypes are the types of the params, the receiver and the context are excluded
+	ArgTypes []reflect.Type
+	// Middlewares is the number of the handlers of the method group and its namespace groups,
+	// the handler calling the method is excluded
+	Middlewares int
+	// Disabled report whether the method is switched off by Disable
+	Disabled bool
+	// Meta is the metadata of the method
+	Meta MethodMeta
+}
+
+// Disable switch off the method at runtime, the calls are responded with err,
+// ServerOption.DisabledError is used when err is omitted
+func (s *server) Disable(method string, err ...error) error {
+	if _, has := s.router.funcs[method]; !has {
+		return ErrMethodNotFound
+	}
+	e := s.option.DisabledError
+	if len(err) > 0 && err[0] != nil {
+		e = err[0]
+	}
+	if e == nil {
+		e = NewError(ErrDisabled, ErrDisabled.Message())
+	}
+	s.mu.Lock()
+	if s.disabled == nil {
+		s.disabled = make(map[string]error)
+	}
+	s.disabled[method] = e
+	s.mu.Unlock()
+	return nil
+}
+
+// Enable switch on the method disabled by Disable
+func (s *server) Enable(method string) error {
+	if _, has := s.router.funcs[method]; !has {
+		return ErrMethodNotFound
+	}
+	s.mu.Lock()
+	delete(s.disabled, method)
+	s.mu.Unlock()
+	return nil
+}
+
+// Err return the joined errors of the registrations, e.g. ErrDuplicateMethod in strict mode
+func (s *server) Err() error { return errors.Join(s.router.errs...) }
+
+// Methods return the registered methods sorted by name
+func (s *server) Methods() []MethodInfo {
+	methods := make([]MethodInfo, 0, len(s.router.funcs))
+	for name, f := range s.router.funcs {
+		info := MethodInfo{
+			Name:     name,
+			ArgTypes: f.paramTypes(),
+			Disabled: s.disabledError(name) != nil,
+			Meta:     f.meta,
+		}
+		if fn := runtime.FuncForPC(f.fn.Pointer()); fn != nil {
+			info.Func = strings.TrimSuffix(fn.Name(), "-fm")
+		}
+		for key, group := range s.groups {
+			if key != "" && (key == name || strings.HasPrefix(name, key+Separator)) {
+				info.Middlewares += len(group.Handlers())
+			}
+		}
+		if info.Middlewares > 0 {
+			info.Middlewares--
+		}
+		methods = append(methods, info)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
+// disabledError return the error of the disabled method, nil when it is enabled
+func (s *server) disabledError(method string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.disabled[method]
+}
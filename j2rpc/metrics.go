@@ -0,0 +1,151 @@
+package j2rpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the latency histogram
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics collect the calls, errors and latency of every method,
+// use Metrics.Handler as a middleware and serve Metrics in the Prometheus text format.
+// The calls of unknown methods aren't recorded to bound the number of series.
+type Metrics struct {
+	mu      sync.Mutex
+	buckets []float64
+	methods map[string]*methodMetrics
+}
+
+type methodMetrics struct {
+	calls   uint64
+	errors  map[ErrorCode]uint64
+	buckets []uint64
+	sum     float64
+}
+
+// Handler return the middleware recording the calls, it reads the begin time from TimeBeginContextKey,
+// e.g. s.Use("", metrics.Handler())
+func (m *Metrics) Handler() Handler {
+	return func(c Context) {
+		passNeutral(c)
+		c.Next()
+		begin, ok := callBegin(c)
+		if !ok {
+			return
+		}
+		msg := c.Msg()
+		if msg.Error != nil && msg.Error.Code == ErrNoMethod {
+			return
+		}
+		var code ErrorCode
+		if msg.Error != nil {
+			code = msg.Error.Code
+		}
+		m.Observe(msg.Method, code, time.Since(begin))
+	}
+}
+
+// Observe record a call of the method, code is 0 when the call succeeds
+func (m *Metrics) Observe(method string, code ErrorCode, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mm, has := m.methods[method]
+	if !has {
+		mm = &methodMetrics{errors: make(map[ErrorCode]uint64), buckets: make([]uint64, len(m.buckets))}
+		m.methods[method] = mm
+	}
+	mm.calls++
+	if code != 0 {
+		mm.errors[code]++
+	}
+	seconds := latency.Seconds()
+	mm.sum += seconds
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			mm.buckets[i]++
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo write the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.NewBuffer(nil)
+	m.mu.Lock()
+	methods := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	buf.WriteString("# HELP j2rpc_calls_total Total number of json-rpc calls.\n")
+	buf.WriteString("# TYPE j2rpc_calls_total counter\n")
+	for _, method := range methods {
+		_, _ = fmt.Fprintf(buf, "j2rpc_calls_total{method=\"%s\"} %d\n", labelReplacer.Replace(method), m.methods[method].calls)
+	}
+
+	buf.WriteString("# HELP j2rpc_errors_total Total number of json-rpc calls failed, by error code.\n")
+	buf.WriteString("# TYPE j2rpc_errors_total counter\n")
+	for _, method := range methods {
+		errs := m.methods[method].errors
+		codes := make([]int, 0, len(errs))
+		for code := range errs {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			_, _ = fmt.Fprintf(buf, "j2rpc_errors_total{method=\"%s\",code=\"%d\"} %d\n",
+				labelReplacer.Replace(method), code, errs[ErrorCode(code)])
+		}
+	}
+
+	buf.WriteString("# HELP j2rpc_call_duration_seconds Latency of the json-rpc calls.\n")
+	buf.WriteString("# TYPE j2rpc_call_duration_seconds histogram\n")
+	for _, method := range methods {
+		mm, label := m.methods[method], labelReplacer.Replace(method)
+		for i, bound := range m.buckets {
+			_, _ = fmt.Fprintf(buf, "j2rpc_call_duration_seconds_bucket{method=\"%s\",le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(bound, 'g', -1, 64), mm.buckets[i])
+		}
+		_, _ = fmt.Fprintf(buf, "j2rpc_call_duration_seconds_bucket{method=\"%s\",le=\"+Inf\"} %d\n", label, mm.calls)
+		_, _ = fmt.Fprintf(buf, "j2rpc_call_duration_seconds_sum{method=\"%s\"} %s\n", label, strconv.FormatFloat(mm.sum, 'g', -1, 64))
+		_, _ = fmt.Fprintf(buf, "j2rpc_call_duration_seconds_count{method=\"%s\"} %d\n", label, mm.calls)
+	}
+	m.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+// callBegin return the begin time of the call, it is false when the request isn't dispatched to a method,
+// e.g. a batch envelope or an invalid body
+func callBegin(c Context) (time.Time, bool) {
+	v, has := c.GetValue(TimeBeginContextKey)
+	if !has {
+		return time.Time{}, false
+	}
+	begin, ok := v.(time.Time)
+	return begin, ok
+}
+
+// NewMetrics create the metrics, DefaultLatencyBuckets are used when buckets is omitted
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{buckets: buckets, methods: make(map[string]*methodMetrics)}
+}
@@ -0,0 +1,57 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	s := newTestServer()
+	s.Use("", metrics.Handler())
+	doRequest(s, `{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]}`)
+	doRequest(s, `[{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]},{"jsonrpc":"2.0","id":2,"method":"arith.fail"}]`)
+	doRequest(s, `{"jsonrpc":"2.0","id":1,"method":"arith.none"}`)
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`j2rpc_calls_total{method="arith.add"} 2`,
+		`j2rpc_calls_total{method="arith.fail"} 1`,
+		`j2rpc_errors_total{method="arith.fail",code="403"} 1`,
+		`j2rpc_call_duration_seconds_bucket{method="arith.add",le="+Inf"} 2`,
+		`j2rpc_call_duration_seconds_count{method="arith.add"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "arith.none") {
+		t.Fatalf("unknown method recorded:\n%s", body)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	const parent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	var spans []*Span
+	var traceID string
+	s := NewServer()
+	s.Use("", Tracing(func(span *Span) { spans = append(spans, span) }))
+	s.RegisterFunc("trace", func(ctx context.Context) string {
+		span, _ := SpanFromContext(ctx)
+		traceID = span.TraceID
+		return span.TraceParent()
+	})
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"trace"}`))
+	req.Header.Set(TraceParentHeader, parent)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	if len(spans) != 1 || traceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatalf("spans=%d traceID=%s", len(spans), traceID)
+	}
+	if span := spans[0]; span.ParentID != "b7ad6b7169203331" || !span.Sampled || span.Method != "trace" {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+}
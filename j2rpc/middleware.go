@@ -6,6 +6,9 @@ import (
 	"runtime"
 )
 
+// neutralContextKey count the middlewares of j2rpc the call passed, they don't depend on the caller
+const neutralContextKey = "___j2rpc.neutral"
+
 type StdLogger interface {
 	Printf(format string, v ...interface{})
 	Print(v ...interface{})
@@ -25,6 +28,7 @@ func Recover(args ...any) Handler {
 	}
 
 	return func(c Context) {
+		passNeutral(c)
 		defer func() {
 			if err := recover(); err != nil {
 				c.Abort()
@@ -38,3 +42,17 @@ func Recover(args ...any) Handler {
 		c.Next()
 	}
 }
+
+// passNeutral record that the call passed a middleware of j2rpc
+func passNeutral(c Context) {
+	v, _ := c.GetValue(neutralContextKey)
+	n, _ := v.(int)
+	c.SetValue(neutralContextKey, n+1)
+}
+
+// neutralPassed return the number of the middlewares of j2rpc the call passed
+func neutralPassed(c Context) int {
+	v, _ := c.GetValue(neutralContextKey)
+	n, _ := v.(int)
+	return n
+}
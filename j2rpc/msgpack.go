@@ -0,0 +1,168 @@
+package j2rpc
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// MsgpackCodec encode the messages with MessagePack, the json tags of the structs are respected
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (MsgpackCodec) Kind(raw RawMessage) RawKind {
+	if len(raw) == 0 {
+		return RawNull
+	}
+	switch c := raw[0]; {
+	case c == msgpcode.Nil:
+		return RawNull
+	case msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32:
+		return RawArray
+	case msgpcode.IsFixedMap(c) || c == msgpcode.Map16 || c == msgpcode.Map32:
+		return RawObject
+	default:
+		return RawValue
+	}
+}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) SplitArray(raw RawMessage) ([]RawMessage, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(raw))
+	n, err := dec.DecodeArrayLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	list := make([]RawMessage, 0, n)
+	for i := 0; i < n; i++ {
+		elem, e := dec.DecodeRaw()
+		if e != nil {
+			return nil, e
+		}
+		list = append(list, RawMessage(elem))
+	}
+	return list, nil
+}
+
+func (MsgpackCodec) SplitObject(raw RawMessage) (map[string]RawMessage, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(raw))
+	n, err := dec.DecodeMapLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	fields := make(map[string]RawMessage, n)
+	for i := 0; i < n; i++ {
+		key, e := dec.DecodeString()
+		if e != nil {
+			return nil, e
+		}
+		value, e := dec.DecodeRaw()
+		if e != nil {
+			return nil, e
+		}
+		fields[key] = RawMessage(value)
+	}
+	return fields, nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// DecodeMsgpack decode the message keeping id, params and result as raw msgpack
+func (r *RPCMessage) DecodeMsgpack(dec *msgpack.Decoder) error {
+	n, err := dec.DecodeMapLen()
+	if err != nil || n < 0 {
+		return err
+	}
+	*r = RPCMessage{}
+	for i := 0; i < n; i++ {
+		key, e := dec.DecodeString()
+		if e != nil {
+			return e
+		}
+		switch key {
+		case "id":
+			r.ID, e = decodeMsgpackRaw(dec)
+		case "jsonrpc":
+			r.Version, e = dec.DecodeString()
+		case "method":
+			r.Method, e = dec.DecodeString()
+		case "params":
+			r.Params, e = decodeMsgpackRaw(dec)
+		case "result":
+			r.Result, e = decodeMsgpackRaw(dec)
+		case "error":
+			e = dec.Decode(&r.Error)
+		case "idempotencyKey":
+			r.IdempotencyKey, e = dec.DecodeString()
+		default:
+			e = dec.Skip()
+		}
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// EncodeMsgpack encode the message writing id, params and result as they are
+func (r *RPCMessage) EncodeMsgpack(enc *msgpack.Encoder) error {
+	type field struct {
+		key   string
+		value interface{}
+	}
+	fields := make([]field, 0, 7)
+	if len(r.ID) > 0 {
+		fields = append(fields, field{"id", msgpack.RawMessage(r.ID)})
+	}
+	if r.Version != "" {
+		fields = append(fields, field{"jsonrpc", r.Version})
+	}
+	if r.Method != "" {
+		fields = append(fields, field{"method", r.Method})
+	}
+	if len(r.Params) > 0 {
+		fields = append(fields, field{"params", msgpack.RawMessage(r.Params)})
+	}
+	if len(r.Result) > 0 {
+		fields = append(fields, field{"result", msgpack.RawMessage(r.Result)})
+	}
+	if r.Error != nil {
+		fields = append(fields, field{"error", r.Error})
+	}
+	if r.IdempotencyKey != "" {
+		fields = append(fields, field{"idempotencyKey", r.IdempotencyKey})
+	}
+	if err := enc.EncodeMapLen(len(fields)); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := enc.EncodeString(f.key); err != nil {
+			return err
+		}
+		if err := enc.Encode(f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMsgpackRaw(dec *msgpack.Decoder) (RawMessage, error) {
+	raw, err := dec.DecodeRaw()
+	return RawMessage(raw), err
+}
@@ -0,0 +1,20 @@
+package j2rpc
+
+import (
+	"context"
+)
+
+// Executor run the task in background, e.g. a goroutine pool
+type Executor func(task func())
+
+// GoExecutor run each task in a new goroutine
+func GoExecutor(task func()) { go task() }
+
+// dispatchNotification hand off the notification to the background executor.
+// The handlers run with a context detached from the http request,
+// so they are not canceled when the response is written.
+func (s *server) dispatchNotification(ctx *rpcContext, raw RawMessage, msg *RPCMessage) {
+	detached := context.WithoutCancel(ctx.req.Context())
+	c, _ := ctx.newBufferedContext(detached, raw, msg)
+	s.option.NotificationExecutor(func() { s.Handler(c) })
+}
@@ -0,0 +1,147 @@
+package j2rpc
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	// DiscoverMethod is the built-in method returning the OpenRPC document
+	DiscoverMethod = "rpc.discover"
+
+	openRPCVersion = "1.2.6"
+)
+
+// OpenRPCDocument is the OpenRPC document of a server, see https://spec.open-rpc.org
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []OpenRPCMethod   `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenRPCMethod struct {
+	Name           string                     `json:"name"`
+	ParamStructure string                     `json:"paramStructure,omitempty"`
+	Params         []OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor  `json:"result,omitempty"`
+	Errors         []OpenRPCError             `json:"errors,omitempty"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name     string      `json:"name"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+type OpenRPCError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+type OpenRPCComponents struct {
+	Schemas map[string]*JSONSchema  `json:"schemas,omitempty"`
+	Errors  map[string]OpenRPCError `json:"errors,omitempty"`
+}
+
+// Discover build the OpenRPC document from the registered methods
+func (s *server) Discover() *OpenRPCDocument {
+	builder := newSchemaBuilder()
+	doc := &OpenRPCDocument{
+		OpenRPC: openRPCVersion,
+		Info:    s.option.DiscoverInfo,
+		Methods: make([]OpenRPCMethod, 0, len(s.router.funcs)),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "j2rpc"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	for name, f := range s.router.funcs {
+		if name == DiscoverMethod {
+			continue
+		}
+		doc.Methods = append(doc.Methods, f.openRPCMethod(builder))
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool { return doc.Methods[i].Name < doc.Methods[j].Name })
+	doc.Components.Schemas = builder.Definitions()
+	doc.Components.Errors = make(map[string]OpenRPCError, len(errorCodeMessages))
+	for code, message := range errorCodeMessages {
+		doc.Components.Errors[sanitizeSchemaName(message)] = OpenRPCError{Code: code, Message: message}
+	}
+	return doc
+}
+
+func (f funcInfo) openRPCMethod(builder *schemaBuilder) OpenRPCMethod {
+	types := f.paramTypes()
+	method := OpenRPCMethod{
+		Name:           f.name,
+		ParamStructure: "by-position",
+		Params:         make([]OpenRPCContentDescriptor, 0, len(types)),
+	}
+	if len(f.meta.ParamNames) > 0 || (len(types) == 1 && isObjectType(types[0])) {
+		method.ParamStructure = "either"
+	}
+	for i, typ := range types {
+		name := "arg" + strconv.Itoa(i)
+		if i < len(f.meta.ParamNames) {
+			name = f.meta.ParamNames[i]
+		}
+		method.Params = append(method.Params, OpenRPCContentDescriptor{
+			Name:     name,
+			Required: !isNillable(typ),
+			Schema:   builder.Schema(typ),
+		})
+	}
+	result := &OpenRPCContentDescriptor{Name: "result", Schema: &JSONSchema{Type: "string", Enum: []interface{}{"Success"}}}
+	if out := f.resultTypes(); len(out) > 0 {
+		result.Schema = builder.Schema(out[0])
+	}
+	method.Result = result
+	for _, code := range f.errorCodes() {
+		method.Errors = append(method.Errors, OpenRPCError{Code: code, Message: code.Message()})
+	}
+	return method
+}
+
+// errorCodes return the error codes the method may respond
+func (f funcInfo) errorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0)
+	if len(f.paramTypes()) > 0 {
+		codes = append(codes, ErrBadParams)
+	}
+	if len(f.meta.Permissions) > 0 {
+		codes = append(codes, ErrForbidden)
+	}
+	if f.meta.Timeout > 0 {
+		codes = append(codes, ErrTimeout)
+	}
+	return append(codes, ErrInternal)
+}
+
+// DiscoverHandler serve the OpenRPC document of the server by http GET
+func DiscoverHandler(s Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := JSONEncode(s.Discover())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	})
+}
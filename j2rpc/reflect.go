@@ -4,6 +4,13 @@ import (
 	"reflect"
 )
 
+func IndirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
 func IndirectValue(val reflect.Value) reflect.Value {
 	for val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -11,6 +18,14 @@ func IndirectValue(val reflect.Value) reflect.Value {
 	return val
 }
 
+// isObjectType report whether the type is decoded from a json object
+func isObjectType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map
+}
+
 func ZeroValue(typ reflect.Type) reflect.Value {
 	if typ == nil {
 		return reflect.Value{}
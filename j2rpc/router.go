@@ -16,6 +16,14 @@ var MethodNameProvider = func(name string) string {
 	return strings.ToLower(name[:1]) + name[1:]
 }
 
+// hookMethods are the methods of the registration interfaces, they aren't rpc methods
+var hookMethods = map[string]struct{}{
+	"RPCMethodProvider": {},
+	"RPCParamNames":     {},
+	"RPCPermissions":    {},
+	"RPCTypeName":       {},
+}
+
 type ImplRPCMethodProvider interface {
 	RPCMethodProvider(methodName string) string
 }
@@ -30,11 +38,54 @@ type funcInfo struct {
 	fn     reflect.Value
 	args   []reflect.Value
 	argTs  []reflect.Type
+	meta   MethodMeta
+}
+
+// paramTypes return the types of the params, the receiver and the context are excluded
+func (f funcInfo) paramTypes() []reflect.Type {
+	types := f.argTs
+	if len(types) > 0 && f.isType {
+		types = types[1:]
+	}
+	if len(types) > 0 && types[0].Implements(contextType) {
+		types = types[1:]
+	}
+	return types
+}
+
+// resultTypes return the types of the results, the trailing error is excluded
+func (f funcInfo) resultTypes() []reflect.Type {
+	fnType := f.fn.Type()
+	types := make([]reflect.Type, 0, fnType.NumOut())
+	for i := 0; i < fnType.NumOut(); i++ {
+		types = append(types, fnType.Out(i))
+	}
+	if n := len(types); n > 0 && types[n-1].Implements(errorType) {
+		types = types[:n-1]
+	}
+	return types
 }
 
 type rpcRouter struct {
 	funcs map[string]funcInfo
 	once  sync.Once
+	// strict collect the registrations of the existing methods as the errors
+	strict bool
+	errs   []error
+}
+
+// exists report whether the method is registered, the duplicate is skipped with a warning
+// or collected as ErrDuplicateMethod in strict mode
+func (r *rpcRouter) exists(methodName string) bool {
+	if _, has := r.funcs[methodName]; !has {
+		return false
+	}
+	if r.strict {
+		r.errs = append(r.errs, fmt.Errorf("j2rpc: %w %s", ErrDuplicateMethod, methodName))
+		return true
+	}
+	slog.Warn("Skip existing methodName", slog.String("methodName", methodName))
+	return true
 }
 
 // lazyInit ...
@@ -45,7 +96,7 @@ func (r *rpcRouter) lazyInit() *rpcRouter {
 	return r
 }
 
-func (r *rpcRouter) registerFunc(val interface{}, methodName string, callback func(f funcInfo)) {
+func (r *rpcRouter) registerFunc(val interface{}, methodName string, opts []MethodOption, callback func(f funcInfo)) {
 	r.lazyInit()
 	vVal := reflect.ValueOf(val)
 	if vVal.IsNil() {
@@ -61,14 +112,12 @@ func (r *rpcRouter) registerFunc(val interface{}, methodName string, callback fu
 		args = append(args, reflect.New(vType.In(i)).Elem())
 		argTs = append(argTs, vType.In(i))
 	}
+	goName := runtime.FuncForPC(vVal.Pointer()).Name()
+	goName = strings.TrimSuffix(goName[strings.LastIndex(goName, Separator)+1:], "-fm")
 	if methodName == "" {
-		runtimeFunc := vVal.Pointer()
-		methodName = runtime.FuncForPC(runtimeFunc).Name()
-		methodName = methodName[strings.LastIndex(methodName, Separator)+1:]
-		methodName = MethodNameProvider(methodName)
+		methodName = MethodNameProvider(goName)
 	}
-	if _, has := r.funcs[methodName]; has {
-		slog.Warn("Skip existing methodName", slog.String("methodName", methodName))
+	if r.exists(methodName) {
 		return
 	}
 	info := funcInfo{
@@ -76,6 +125,7 @@ func (r *rpcRouter) registerFunc(val interface{}, methodName string, callback fu
 		fn:    vVal,
 		args:  args,
 		argTs: argTs,
+		meta:  newMethodMeta(MethodMeta{Name: methodName, GoName: goName}, opts),
 	}
 	r.funcs[methodName] = info
 	if callback != nil {
@@ -84,7 +134,7 @@ func (r *rpcRouter) registerFunc(val interface{}, methodName string, callback fu
 }
 
 // registerType ...
-func (r *rpcRouter) registerType(val interface{}, typeName string, callback func(f funcInfo)) {
+func (r *rpcRouter) registerType(val interface{}, typeName string, opts []MethodOption, callback func(f funcInfo)) {
 	r.lazyInit()
 	if val == nil {
 		return
@@ -101,12 +151,14 @@ func (r *rpcRouter) registerType(val interface{}, typeName string, callback func
 	numMethod := vType.NumMethod()
 	for i := 0; i < numMethod; i++ {
 		m := vType.Method(i)
+		if _, skip := hookMethods[m.Name]; skip {
+			continue
+		}
 		methodName := fmt.Sprintf("%s%s%s", typeName, Separator, MethodNameProvider(m.Name))
 		if _v, ok := val.(ImplRPCMethodProvider); ok {
 			methodName = _v.RPCMethodProvider(m.Name)
 		}
-		if _, has := r.funcs[methodName]; has {
-			slog.Warn("Skip existing methodName", slog.String("methodName", methodName))
+		if r.exists(methodName) {
 			continue
 		}
 		mType := m.Type
@@ -116,12 +168,20 @@ func (r *rpcRouter) registerType(val interface{}, typeName string, callback func
 			args = append(args, reflect.New(mType.In(j)).Elem())
 			argTs = append(argTs, mType.In(j))
 		}
+		meta := MethodMeta{Name: methodName, GoName: m.Name}
+		if _v, ok := val.(ImplRPCParamNames); ok {
+			meta.ParamNames = _v.RPCParamNames(m.Name)
+		}
+		if _v, ok := val.(ImplRPCPermissions); ok {
+			meta.Permissions = _v.RPCPermissions(m.Name)
+		}
 		info := funcInfo{
 			isType: true,
 			name:   methodName,
 			fn:     m.Func,
 			args:   args,
 			argTs:  argTs,
+			meta:   newMethodMeta(meta, opts),
 		}
 		r.funcs[methodName] = info
 		if callback != nil {
@@ -129,3 +189,10 @@ func (r *rpcRouter) registerType(val interface{}, typeName string, callback func
 		}
 	}
 }
+
+func newMethodMeta(meta MethodMeta, opts []MethodOption) MethodMeta {
+	for _, opt := range opts {
+		opt(&meta)
+	}
+	return meta
+}
@@ -0,0 +1,271 @@
+package j2rpc
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	rawMessageType    = reflect.TypeOf(RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// JSONSchema is the subset of JSON Schema used to describe params and results
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+}
+
+// schemaBuilder reflect go types to json schemas,
+// named struct types are collected as definitions and referenced by $ref
+type schemaBuilder struct {
+	defs  map[string]*JSONSchema
+	names map[reflect.Type]string
+}
+
+// Definitions return the collected schemas of the named struct types
+func (b *schemaBuilder) Definitions() map[string]*JSONSchema { return b.defs }
+
+// Schema return the json schema of the type
+func (b *schemaBuilder) Schema(typ reflect.Type) *JSONSchema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case typ == rawMessageType:
+		return &JSONSchema{}
+	case typ.Kind() != reflect.Struct && reflect.PointerTo(typ).Implements(textMarshalerType):
+		return &JSONSchema{Type: "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+		return &JSONSchema{Type: "array", Items: b.Schema(typ.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.Schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return b.structSchema(typ)
+		}
+		name, has := b.names[typ]
+		if !has {
+			name = b.definitionName(typ)
+			b.names[typ] = name
+			// reserve the name first, the struct may refer to itself
+			b.defs[name] = &JSONSchema{}
+			*b.defs[name] = *b.structSchema(typ)
+			b.defs[name].Title = typ.Name()
+		}
+		return &JSONSchema{Ref: schemaRefPrefix + name}
+	default:
+		return &JSONSchema{}
+	}
+}
+
+func (b *schemaBuilder) definitionName(typ reflect.Type) string {
+	name := sanitizeSchemaName(typ.Name())
+	if _, has := b.defs[name]; !has {
+		return name
+	}
+	pkg := typ.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	name = sanitizeSchemaName(pkg) + "_" + name
+	for i := 2; ; i++ {
+		if _, has := b.defs[name]; !has {
+			return name
+		}
+		name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+	}
+}
+
+// structSchema build the object schema following the encoding/json field rules
+func (b *schemaBuilder) structSchema(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	for _, field := range reflect.VisibleFields(typ) {
+		if len(field.Index) > 1 {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		isEmbedded := field.Anonymous && name == "" && IndirectType(field.Type).Kind() == reflect.Struct
+		if !field.IsExported() && !isEmbedded {
+			continue
+		}
+		if isEmbedded {
+			embedded := b.structSchema(IndirectType(field.Type))
+			for k, v := range embedded.Properties {
+				if _, has := schema.Properties[k]; !has {
+					schema.Properties[k] = v
+				}
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := b.Schema(field.Type)
+		if strings.Contains(opts, "string") && prop.Ref == "" {
+			prop = &JSONSchema{Type: "string"}
+		}
+		if applyValidateTag(prop, IndirectType(field.Type), field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// applyValidateTag translate the validate tag to schema keywords,
+// it reports whether the field is required
+func applyValidateTag(schema *JSONSchema, typ reflect.Type, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if key == "dive" {
+			// the rest rules are for the elements
+			break
+		}
+		if schema.Ref != "" && key != "required" {
+			continue
+		}
+		switch key {
+		case "required":
+			required = true
+		case "len":
+			setSchemaBound(schema, typ, "min", param)
+			setSchemaBound(schema, typ, "max", param)
+		case "min", "gte":
+			setSchemaBound(schema, typ, "min", param)
+		case "max", "lte":
+			setSchemaBound(schema, typ, "max", param)
+		case "gt":
+			setSchemaBound(schema, typ, "gt", param)
+		case "lt":
+			setSchemaBound(schema, typ, "lt", param)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && schema.Type != "string" {
+					schema.Enum = append(schema.Enum, f)
+					continue
+				}
+				schema.Enum = append(schema.Enum, v)
+			}
+		case "email", "mail":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		case "ipv4", "ipv6", "hostname":
+			schema.Format = key
+		case "datetime":
+			schema.Format = "date-time"
+		case "mobile", "phone", "tel", "telephone":
+			schema.Pattern = `^1[3456789]\d{9}$`
+		case "numeric":
+			schema.Pattern = `^[-+]?[0-9]+(?:\.[0-9]+)?$`
+		case "alpha":
+			schema.Pattern = `^[a-zA-Z]+$`
+		case "alphanum":
+			schema.Pattern = `^[a-zA-Z0-9]+$`
+		}
+	}
+	return
+}
+
+// setSchemaBound set the length limit for strings and lists, the value limit for numbers
+func setSchemaBound(schema *JSONSchema, typ reflect.Type, bound, param string) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	n := int(f)
+	switch typ.Kind() {
+	case reflect.String:
+		switch bound {
+		case "min":
+			schema.MinLength = &n
+		case "max":
+			schema.MaxLength = &n
+		}
+	case reflect.Slice, reflect.Array:
+		switch bound {
+		case "min":
+			schema.MinItems = &n
+		case "max":
+			schema.MaxItems = &n
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		switch bound {
+		case "min":
+			schema.Minimum = &f
+		case "max":
+			schema.Maximum = &f
+		case "gt":
+			schema.ExclusiveMinimum = &f
+		case "lt":
+			schema.ExclusiveMaximum = &f
+		}
+	}
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		defs:  make(map[string]*JSONSchema),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+func sanitizeSchemaName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -1,10 +1,14 @@
 package j2rpc
 
 import (
+	"context"
 	"net/http"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/glibtools/libs/util"
 )
 
 type CallerBody func([]byte) ([]byte, error)
@@ -13,17 +17,64 @@ type Option func(option *ServerOption)
 
 type PrepareRequestBodyFuncType = func(*http.Request, []byte) ([]byte, error)
 
+type PrepareResponseBodyFuncType = func(http.ResponseWriter, *http.Request, []byte) ([]byte, error)
+
 type ServerOption struct {
 	CallerAfterReadBody CallerBody
 	CallerBeforeWrite   CallerBody
 	PrepareWriter       func(http.ResponseWriter)
-	PrepareRequestBody  PrepareRequestBodyFuncType
+	// PrepareRequestBody transform the request body before it is decoded, the body of a GET request is its params,
+	// e.g. WithCrypto
+	PrepareRequestBody PrepareRequestBodyFuncType
+	// PrepareResponseBody transform the encoded response body after CallerBeforeWrite, e.g. WithCrypto
+	PrepareResponseBody PrepareResponseBodyFuncType
+	// BatchLimit is the max number of calls in a batch request, default 100
+	BatchLimit int
+	// BatchConcurrency is the number of batch calls handled at the same time, default 1
+	BatchConcurrency int
+	// NotificationExecutor run notifications in background when it is set,
+	// the response is written before the method is called
+	NotificationExecutor Executor
+	// DisableDiscover disable the built-in rpc.discover method
+	DisableDiscover bool
+	// DiscoverInfo is the info of the OpenRPC document
+	DiscoverInfo OpenRPCInfo
+	// MaxTimeout cap the timeout requested by the client with the TimeoutHeader,
+	// the header is ignored when it is zero
+	MaxTimeout time.Duration
+	// Validator validate the struct arguments by the validate tags before the method is invoked,
+	// the validation is disabled when it is nil
+	Validator *util.Validator
+	// Codecs are the codecs selected by the Content-Type of the request besides JSONCodec
+	Codecs []Codec
+	// Authorizer check the methods declaring permissions, they are denied when it is nil
+	Authorizer Authorizer
+	// DisabledError is the default response of the methods switched off by Disable
+	DisabledError error
+	// StrictRegister collect the registrations of the existing methods as the errors of Server.Err
+	// instead of skipping them with a warning
+	StrictRegister bool
+	// MaxRequestSize is the max size in bytes of a request body after decompression, default 5MB, 0 means no limit
+	MaxRequestSize int64
+	// MaxBatchSize is the max size in bytes of a batch request body, MaxRequestSize is applied when it is 0
+	MaxBatchSize int64
+	// CompressEncodings are the response encodings in the order of preference negotiated by Accept-Encoding,
+	// the compression is disabled when it is empty
+	CompressEncodings []string
+	// CompressMinSize is the min size in bytes of the compressed responses
+	CompressMinSize int
+	// AllowGet allow the safe methods to be called by GET /rpc/{method}?params=<urlencoded json>,
+	// the responses have the ETag and Cache-Control headers
+	AllowGet bool
 }
 
 type server struct {
-	groups map[string]Group
-	router *rpcRouter
-	option *ServerOption
+	groups   map[string]Group
+	router   *rpcRouter
+	option   *ServerOption
+	subs     subscriptions
+	mu       sync.RWMutex
+	disabled map[string]error
 }
 
 // Handler ...
@@ -38,13 +89,13 @@ func (s *server) Handler(c Context) {
 func (s *server) Option() *ServerOption { return s.option }
 
 func (s *server) RegisterFunc(args ...interface{}) {
-	name, fn := argsToNameInterface(args...)
-	s.router.registerFunc(fn, name, func(f funcInfo) { s.Use(f.name, s.handleCallFunc(f)) })
+	name, fn, opts := argsToNameInterface(args...)
+	s.router.registerFunc(fn, name, opts, s.registerHandler)
 }
 
 func (s *server) RegisterType(args ...interface{}) {
-	name, bean := argsToNameInterface(args...)
-	s.router.registerType(bean, name, func(f funcInfo) { s.Use(f.name, s.handleCallFunc(f)) })
+	name, bean, opts := argsToNameInterface(args...)
+	s.router.registerType(bean, name, opts, s.registerHandler)
 }
 
 // RegisterTypeBus reg type bus
@@ -63,15 +114,12 @@ func (s *server) RegisterTypeBus(bus interface{}) {
 		if field.CanSet() && field.Kind() == reflect.Ptr && field.IsNil() {
 			field.Set(reflect.New(field.Type().Elem()))
 		}
-		tagSlice := strings.Split(tag, ",")
-		var tagName string
-		for _, v := range tagSlice {
-			if strings.HasPrefix(v, "name:") || !strings.Contains(v, ":") {
-				tagName = strings.TrimPrefix(v, "name:")
-				break
-			}
+		tagName, opts := parseTypeTag(tag)
+		args := []interface{}{field.Interface(), tagName}
+		for _, opt := range opts {
+			args = append(args, opt)
 		}
-		s.RegisterType(field.Interface(), tagName)
+		s.RegisterType(args...)
 	}
 }
 
@@ -98,6 +146,24 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 		if c.Wrote() {
 			return
 		}
+		if err := s.authorize(c, f); err != nil {
+			c.WriteResponse(err)
+			return
+		}
+		var sub *Subscription
+		if f.isSubscription() {
+			var err error
+			if sub, err = s.newSubscription(c, f); err != nil {
+				c.WriteResponse(err)
+				return
+			}
+		}
+		var deadline context.Context
+		if timeout := s.methodTimeout(c, f); timeout > 0 && sub == nil {
+			var cancel context.CancelFunc
+			deadline, cancel = context.WithTimeout(c.GetContext(), timeout)
+			defer cancel()
+		}
 		argValues := make([]reflect.Value, 0, len(f.args))
 		argTypes := make([]reflect.Type, len(f.args))
 		copy(argTypes, f.argTs)
@@ -110,6 +176,9 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 			switch {
 			case argTypes[0].Implements(rpcContextType):
 				ctxVal = reflect.ValueOf(c)
+				if deadline != nil {
+					ctxVal = reflect.ValueOf(&deadlineContext{Context: c, deadline: deadline})
+				}
 			default:
 				_ctx, ok := c.(*rpcContext)
 				if !ok {
@@ -117,27 +186,63 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 					return
 				}
 				ctxVal = reflect.ValueOf(_ctx.GetContext())
+				if sub != nil {
+					ctxVal = reflect.ValueOf(sub.Context())
+				}
+				if deadline != nil {
+					ctxVal = reflect.ValueOf(deadline)
+				}
 			}
 			argTypes = argTypes[1:]
 			argValues = append(argValues, ctxVal)
 		}
-		values, err := parsePositionalArguments(c.Msg().Params, argTypes)
+		values, err := parseArguments(c.Codec(), c.Msg().Params, argTypes, f.meta.ParamNames)
 		if err != nil {
+			if sub != nil {
+				sub.Unsubscribe()
+			}
 			c.WriteResponse(NewError(ErrBadParams, err.Error()))
 			return
 		}
+		if err = s.validateArguments(values); err != nil {
+			if sub != nil {
+				sub.Unsubscribe()
+			}
+			c.WriteResponse(err)
+			return
+		}
+		if sub == nil && runBeforeCall(c) {
+			return
+		}
 		argValues = append(argValues, values...)
-		results := f.fn.Call(argValues)
+		var results []reflect.Value
+		if deadline != nil {
+			if results, err = callWithDeadline(deadline, f.fn, argValues); err != nil {
+				c.WriteResponse(err)
+				return
+			}
+		} else {
+			results = f.fn.Call(argValues)
+		}
 		if len(results) > 0 {
 			last := results[len(results)-1]
 			if last.Type().Implements(errorType) {
 				if !last.IsNil() {
+					if sub != nil {
+						sub.Unsubscribe()
+					}
 					c.WriteResponse(last.Interface().(error))
 					return
 				}
 				results = results[:len(results)-1]
 			}
 		}
+		if sub != nil {
+			data, _ := c.Codec().Marshal(sub.ID)
+			c.WriteResponse(data)
+			s.startSubscription(c, sub, results[0])
+			return
+		}
 		if len(results) == 0 {
 			c.WriteResponse()
 			return
@@ -148,7 +253,7 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 			c.WriteResponse()
 			return
 		}
-		data, e := JSONEncode(ret.Interface())
+		data, e := c.Codec().Marshal(ret.Interface())
 		if e != nil {
 			c.WriteResponse(NewError(ErrInternal, e.Error()))
 			return
@@ -157,6 +262,14 @@ func (s *server) handleCallFunc(f funcInfo) Handler {
 	}
 }
 
+// registerHandler add the handler of the registered method
+func (s *server) registerHandler(f funcInfo) {
+	s.Use(f.name, s.handleCallFunc(f))
+	if f.isSubscription() {
+		s.registerUnsubscribe(f.namespace())
+	}
+}
+
 func (s *server) handleReadBody() Handler {
 	return func(c Context) {
 		defer c.Next()
@@ -170,6 +283,19 @@ func (s *server) handleReadBody() Handler {
 		if c.Wrote() {
 			return
 		}
+		if batch := ctx.batchMessages(); batch != nil {
+			c.Abort()
+			s.handleBatch(ctx, batch)
+			return
+		}
+		if msg := ctx.Msg(); msg.IsNotification() && s.option.NotificationExecutor != nil && !ctx.buffered {
+			c.Abort()
+			body, _ := c.GetValue(BodyContextKey)
+			raw, _ := body.([]byte)
+			s.dispatchNotification(ctx, raw, msg)
+			ctx.writeNoContent()
+			return
+		}
 		c.SetValue(TimeBeginContextKey, time.Now())
 		method := ctx.Msg().Method
 		group, has := s.groups[method]
@@ -177,6 +303,14 @@ func (s *server) handleReadBody() Handler {
 			c.WriteResponse(NewError(ErrNoMethod, "wrong method"))
 			return
 		}
+		if err := s.disabledError(method); err != nil {
+			c.WriteResponse(err)
+			return
+		}
+		if isGetRequest(c.Request()) && !s.router.funcs[method].meta.Safe {
+			c.StopWriteStringStatus(http.StatusMethodNotAllowed, "method isn't safe")
+			return
+		}
 		handlers := make([]Handler, 0)
 		for _key, _group := range s.groups {
 			if _key != "" && method != _key && strings.HasPrefix(method, _key+Separator) {
@@ -194,13 +328,49 @@ func NewServer(opt ...Option) Server {
 		router: &rpcRouter{},
 	}
 	s.Use("", s.handleReadBody())
-	s.option = &ServerOption{}
+	s.option = &ServerOption{
+		BatchLimit:       defaultBatchLimit,
+		BatchConcurrency: defaultBatchConcurrency,
+		MaxRequestSize:   maxRequestContentLength,
+	}
 	for _, o := range opt {
 		o(s.option)
 	}
+	s.router.strict = s.option.StrictRegister
+	if !s.option.DisableDiscover {
+		s.RegisterFunc(DiscoverMethod, s.Discover)
+	}
 	return s
 }
 
+// WithAllowGet allow the safe methods to be called by GET, see WithSafe
+func WithAllowGet() Option {
+	return func(option *ServerOption) {
+		option.AllowGet = true
+	}
+}
+
+// WithAuthorizer set the authorizer of the methods declaring permissions
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(option *ServerOption) {
+		option.Authorizer = authorizer
+	}
+}
+
+// WithBatchConcurrency set how many calls of a batch are handled concurrently
+func WithBatchConcurrency(n int) Option {
+	return func(option *ServerOption) {
+		option.BatchConcurrency = n
+	}
+}
+
+// WithBatchLimit set the max number of calls in a batch, 0 means no limit
+func WithBatchLimit(n int) Option {
+	return func(option *ServerOption) {
+		option.BatchLimit = n
+	}
+}
+
 func WithCallerAfterReadBody(fn CallerBody) Option {
 	return func(option *ServerOption) {
 		option.CallerAfterReadBody = fn
@@ -213,14 +383,104 @@ func WithCallerBeforeWrite(fn CallerBody) Option {
 	}
 }
 
+// WithCodec add the codecs selected by the Content-Type of the request, e.g. MsgpackCodec
+func WithCodec(codecs ...Codec) Option {
+	return func(option *ServerOption) {
+		option.Codecs = append(option.Codecs, codecs...)
+	}
+}
+
+// WithCompression compress the responses not smaller than minSize with the encodings negotiated by Accept-Encoding,
+// DefaultEncodings are used when encodings are omitted, minSize defaults to 1KB when it is negative
+func WithCompression(minSize int, encodings ...string) Option {
+	return func(option *ServerOption) {
+		if len(encodings) == 0 {
+			encodings = DefaultEncodings
+		}
+		if minSize < 0 {
+			minSize = defaultCompressMinSize
+		}
+		option.CompressEncodings = encodings
+		option.CompressMinSize = minSize
+	}
+}
+
+// WithDiscover set the info of the OpenRPC document, or disable the rpc.discover method
+func WithDiscover(info OpenRPCInfo, disable ...bool) Option {
+	return func(option *ServerOption) {
+		option.DiscoverInfo = info
+		option.DisableDiscover = len(disable) > 0 && disable[0]
+	}
+}
+
+// WithDisabledError set the default response of the methods switched off by Disable
+func WithDisabledError(err error) Option {
+	return func(option *ServerOption) {
+		option.DisabledError = err
+	}
+}
+
+// WithMaxBatchSize set the max size in bytes of a batch request body
+func WithMaxBatchSize(n int64) Option {
+	return func(option *ServerOption) {
+		option.MaxBatchSize = n
+	}
+}
+
+// WithMaxRequestSize set the max size in bytes of a request body, 0 means no limit
+func WithMaxRequestSize(n int64) Option {
+	return func(option *ServerOption) {
+		option.MaxRequestSize = n
+	}
+}
+
+// WithMaxTimeout honour the TimeoutHeader of the client, capped by d
+func WithMaxTimeout(d time.Duration) Option {
+	return func(option *ServerOption) {
+		option.MaxTimeout = d
+	}
+}
+
+// WithNotificationExecutor run notifications in background with the executor
+func WithNotificationExecutor(executor Executor) Option {
+	return func(option *ServerOption) {
+		option.NotificationExecutor = executor
+	}
+}
+
 func WithPrepareRequestBody(fn PrepareRequestBodyFuncType) Option {
 	return func(option *ServerOption) {
 		option.PrepareRequestBody = fn
 	}
 }
 
+// WithPrepareResponseBody transform the encoded response body with the request
+func WithPrepareResponseBody(fn PrepareResponseBodyFuncType) Option {
+	return func(option *ServerOption) {
+		option.PrepareResponseBody = fn
+	}
+}
+
 func WithPrepareWriter(fn func(http.ResponseWriter)) Option {
 	return func(option *ServerOption) {
 		option.PrepareWriter = fn
 	}
 }
+
+// WithStrictRegister collect the registrations of the existing methods as the errors of Server.Err
+func WithStrictRegister() Option {
+	return func(option *ServerOption) {
+		option.StrictRegister = true
+	}
+}
+
+// WithValidator validate the struct arguments before the method is invoked,
+// util.ValidatorInc is used when v is omitted
+func WithValidator(v ...*util.Validator) Option {
+	return func(option *ServerOption) {
+		option.Validator = util.ValidatorInc
+		if len(v) > 0 && v[0] != nil {
+			option.Validator = v[0]
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type testArith struct{}
+
+func (testArith) Add(a, b int) int { return a + b }
+
+func (testArith) Fail(context.Context) error { return NewError(ErrForbidden, "forbidden") }
+
+func (testArith) Sum(_ context.Context, arg struct{ A, B int }) int { return arg.A + arg.B }
+
+func (testArith) Sub(a, b int) int { return a - b }
+
+func (testArith) RPCParamNames(methodName string) []string {
+	if methodName == "Sub" {
+		return []string{"a", "b"}
+	}
+	return nil
+}
+
+func newTestServer(opts ...Option) Server {
+	s := NewServer(opts...)
+	s.RegisterType(&testArith{}, "arith")
+	return s
+}
+
+func doRequest(s Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestServer_Single(t *testing.T) {
+	w := doRequest(newTestServer(), `{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]}`)
+	msg := &RPCMessage{}
+	if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Result) != "3" {
+		t.Fatalf("result=%s want=3", msg.Result)
+	}
+}
+
+func TestServer_NullArguments(t *testing.T) {
+	s := newTestServer()
+	s.RegisterFunc("optional", func(a *int, b []int) bool { return a == nil && b == nil })
+	cases := []struct {
+		body   string
+		result string
+		code   ErrorCode
+	}{
+		{`{"id":1,"method":"arith.add","params":[1,null]}`, "", ErrBadParams},
+		{`{"id":1,"method":"arith.add","params":[1]}`, "1", 0},
+		{`{"id":1,"method":"optional","params":[null,null]}`, "true", 0},
+	}
+	for _, v := range cases {
+		msg := &RPCMessage{}
+		if err := JSONDecode(doRequest(s, v.body).Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		var code ErrorCode
+		if msg.Error != nil {
+			code = msg.Error.Code
+		}
+		if string(msg.Result) != v.result || code != v.code {
+			t.Fatalf("%s: result=%s error=%v", v.body, msg.Result, msg.Error)
+		}
+		if v.code != 0 && msg.Error.Message != "missing value for required argument 1" {
+			t.Fatalf("%s: message=%q", v.body, msg.Error.Message)
+		}
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	var calls int32
+	s := newTestServer(WithBatchConcurrency(4))
+	s.Use("arith", func(c Context) {
+		atomic.AddInt32(&calls, 1)
+		c.Next()
+	})
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]},
+		{"jsonrpc":"2.0","id":2,"method":"arith.fail"},
+		{"jsonrpc":"2.0","id":3,"method":"arith.none"},
+		{"jsonrpc":"2.0","id":{},"method":"arith.add"},
+		{"jsonrpc":"2.0","method":"arith.add","params":[1,1]},
+		1
+	]`
+	w := doRequest(s, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var list []*RPCMessage
+	if err := JSONDecode(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 5 {
+		t.Fatalf("len=%d want=5", len(list))
+	}
+	if string(list[0].ID) != "1" || string(list[0].Result) != "3" {
+		t.Fatalf("unexpected first response: %+v", list[0])
+	}
+	codes := []ErrorCode{ErrForbidden, ErrNoMethod, ErrInvalidRequest, ErrInvalidRequest}
+	for i, code := range codes {
+		if e := list[i+1].Error; e == nil || e.Code != code {
+			t.Fatalf("response %d: error=%v want code %d", i+1, e, code)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("group middleware calls=%d want=3", calls)
+	}
+}
+
+func TestServer_BatchLimit(t *testing.T) {
+	w := doRequest(newTestServer(WithBatchLimit(1)), `[{"id":1,"method":"arith.add"},{"id":2,"method":"arith.add"}]`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status=%d want=%d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	w = doRequest(newTestServer(), `[]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d want=%d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServer_Notification(t *testing.T) {
+	called := make(chan struct{}, 4)
+	s := newTestServer()
+	s.RegisterFunc("notify", func() { called <- struct{}{} })
+	w := doRequest(s, `{"jsonrpc":"2.0","method":"notify"}`)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("status=%d body=%q", w.Code, w.Body.String())
+	}
+	if len(called) != 1 {
+		t.Fatal("notification method not called")
+	}
+	w = doRequest(s, `[{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"notify"}]`)
+	if w.Code != http.StatusNoContent || len(called) != 3 {
+		t.Fatalf("status=%d calls=%d", w.Code, len(called))
+	}
+}
+
+func TestServer_NotificationExecutor(t *testing.T) {
+	release, done := make(chan struct{}), make(chan struct{})
+	s := newTestServer(WithNotificationExecutor(GoExecutor))
+	s.RegisterFunc("slow", func(ctx context.Context) {
+		<-release
+		if ctx.Err() == nil {
+			close(done)
+		}
+	})
+	w := doRequest(s, `{"jsonrpc":"2.0","method":"slow"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status=%d", w.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestServer_NamedParams(t *testing.T) {
+	s := newTestServer()
+	s.RegisterFunc("mul", func(a, b int) int { return a * b }, WithParamNames("x", "y"))
+	cases := []struct {
+		body   string
+		result string
+		code   ErrorCode
+	}{
+		{`{"id":1,"method":"arith.sum","params":{"A":2,"B":3}}`, "5", 0},
+		{`{"id":1,"method":"arith.sub","params":{"b":1,"a":5}}`, "4", 0},
+		{`{"id":1,"method":"arith.sub","params":{"a":5}}`, "5", 0},
+		{`{"id":1,"method":"arith.sub","params":{"c":5}}`, "", ErrBadParams},
+		{`{"id":1,"method":"arith.add","params":{"a":5}}`, "", ErrBadParams},
+		{`{"id":1,"method":"mul","params":{"x":2,"y":4}}`, "8", 0},
+	}
+	for _, tc := range cases {
+		msg := &RPCMessage{}
+		if err := JSONDecode(doRequest(s, tc.body).Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		if tc.code != 0 {
+			if msg.Error == nil || msg.Error.Code != tc.code {
+				t.Fatalf("%s: error=%v want code %d", tc.body, msg.Error, tc.code)
+			}
+			continue
+		}
+		if msg.Error != nil || string(msg.Result) != tc.result {
+			t.Fatalf("%s: result=%s error=%v want %s", tc.body, msg.Result, msg.Error, tc.result)
+		}
+	}
+}
+
+func TestServer_RegisterTypeBusParamsTag(t *testing.T) {
+	s := NewServer()
+	s.RegisterTypeBus(&struct {
+		Arith *testArith `j2rpc:"name:calc,params:Add=x|y"`
+	}{})
+	msg := &RPCMessage{}
+	body := doRequest(s, `{"id":1,"method":"calc.add","params":{"x":1,"y":2}}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Result) != "3" {
+		t.Fatalf("result=%s error=%v", msg.Result, msg.Error)
+	}
+}
+
+func TestServer_HookMethodsNotRegistered(t *testing.T) {
+	msg := &RPCMessage{}
+	body := doRequest(newTestServer(), `{"id":1,"method":"arith.rPCParamNames","params":["Sub"]}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error == nil || msg.Error.Code != ErrNoMethod {
+		t.Fatalf("error=%v want code %d", msg.Error, ErrNoMethod)
+	}
+}
+
+func TestServer_Discover(t *testing.T) {
+	type user struct {
+		Name  string `json:"name" validate:"required,min=2,max=20"`
+		Email string `json:"email,omitempty" validate:"email"`
+		Age   int    `json:"age" validate:"gte=0,lte=150"`
+	}
+	s := newTestServer()
+	s.RegisterFunc("createUser", func(_ context.Context, u *user) (*user, error) { return u, nil })
+	s.RegisterFunc("findUsers", func(ids []int, filter map[string]string, limit int) []int { return ids })
+	msg := &RPCMessage{}
+	if err := JSONDecode(doRequest(s, `{"id":1,"method":"rpc.discover"}`).Body.Bytes(), msg); err != nil {
+		t.Fatal(err)
+	}
+	doc := &OpenRPCDocument{}
+	if err := JSONDecode(msg.Result, doc); err != nil {
+		t.Fatal(err)
+	}
+	var method, find *OpenRPCMethod
+	for i := range doc.Methods {
+		switch doc.Methods[i].Name {
+		case DiscoverMethod:
+			t.Fatal("rpc.discover should not be listed")
+		case "createUser":
+			method = &doc.Methods[i]
+		case "findUsers":
+			find = &doc.Methods[i]
+		}
+	}
+	// the nillable params accept null, they aren't required
+	if find == nil || len(find.Params) != 3 || find.Params[0].Required || find.Params[1].Required || !find.Params[2].Required {
+		t.Fatalf("unexpected params: %+v", find)
+	}
+	if method == nil || len(method.Params) != 1 || method.ParamStructure != "either" {
+		t.Fatalf("unexpected method: %+v", method)
+	}
+	schema := doc.Components.Schemas["user"]
+	if schema == nil || method.Params[0].Schema.Ref != "#/components/schemas/user" {
+		t.Fatalf("unexpected schemas: %+v", doc.Components.Schemas)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Fatalf("required=%v want=[name]", schema.Required)
+	}
+	if p := schema.Properties["name"]; p.MinLength == nil || *p.MinLength != 2 || *p.MaxLength != 20 {
+		t.Fatalf("unexpected name schema: %+v", p)
+	}
+	if p := schema.Properties["email"]; p.Format != "email" {
+		t.Fatalf("unexpected email schema: %+v", p)
+	}
+	if p := schema.Properties["age"]; p.Type != "integer" || *p.Maximum != 150 {
+		t.Fatalf("unexpected age schema: %+v", p)
+	}
+}
+
+func TestServer_Validator(t *testing.T) {
+	type login struct {
+		Username string `json:"username" validate:"required"`
+		Age      int    `json:"age" validate:"gte=18"`
+	}
+	s := NewServer(WithValidator())
+	s.RegisterFunc("login", func(arg *login) string { return arg.Username })
+	msg := &RPCMessage{}
+	body := doRequest(s, `{"id":1,"method":"login","params":{"age":1}}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error == nil || msg.Error.Code != ErrBadParams {
+		t.Fatalf("error=%v want code %d", msg.Error, ErrBadParams)
+	}
+	var fields []struct{ Field, Tag string }
+	data, _ := JSONEncode(msg.Error.Data)
+	if err := JSONDecode(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || fields[0].Field != "Username" || fields[1].Tag != "gte" {
+		t.Fatalf("unexpected field errors: %s", data)
+	}
+	msg = &RPCMessage{}
+	body = doRequest(s, `{"id":2,"method":"login","params":{"username":"tom","age":20}}`).Body.Bytes()
+	if err := JSONDecode(body, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error != nil || string(msg.Result) != `"tom"` {
+		t.Fatalf("result=%s error=%v", msg.Result, msg.Error)
+	}
+}
+
+func TestServer_MsgpackCodec(t *testing.T) {
+	codec := MsgpackCodec{}
+	body, err := codec.Marshal(&RPCMessage{ID: RawMessage{0x01}, Version: "2.0", Method: "arith.add", Params: RawMessage{0x92, 0x01, 0x02}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", codec.ContentType())
+	w := httptest.NewRecorder()
+	newTestServer(WithCodec(codec)).ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); ct != codec.ContentType() {
+		t.Fatalf("content-type=%s", ct)
+	}
+	msg := &RPCMessage{}
+	if err = codec.Unmarshal(w.Body.Bytes(), msg); err != nil {
+		t.Fatal(err)
+	}
+	var sum int
+	if err = codec.Unmarshal(msg.Result, &sum); err != nil || sum != 3 || string(msg.ID) != "\x01" {
+		t.Fatalf("sum=%d id=%x err=%v", sum, msg.ID, err)
+	}
+}
+
+type testCaller []string
+
+func (c testCaller) RPCGrantedPermissions() []string { return c }
+
+func TestServer_Permissions(t *testing.T) {
+	s := NewServer(WithAuthorizer(PermissionAuthorizer(ContextValueCaller("caller"))))
+	s.Use("", func(c Context) {
+		if role := c.Request().Header.Get("X-Role"); role != "" {
+			c.SetValue("caller", testCaller{role})
+		}
+		c.Next()
+	})
+	s.RegisterTypeBus(&struct {
+		Arith *testArith `j2rpc:"name:calc,perms:Sub=admin|root"`
+	}{})
+	s.RegisterFunc("open", func() string { return "ok" })
+	if perms := s.Permissions(); len(perms) != 1 || len(perms["calc.sub"]) != 2 {
+		t.Fatalf("permissions=%v", perms)
+	}
+	call := func(role, body string) *RPCMessage {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+		req.Header.Set("X-Role", role)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		msg := &RPCMessage{}
+		if err := JSONDecode(w.Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	for _, tc := range []struct {
+		role, body string
+		code       ErrorCode
+	}{
+		{"", `{"id":1,"method":"calc.sub","params":[3,1]}`, ErrForbidden},
+		{"guest", `{"id":1,"method":"calc.sub","params":[3,1]}`, ErrForbidden},
+		{"root", `{"id":1,"method":"calc.sub","params":[3,1]}`, 0},
+		{"", `{"id":1,"method":"calc.add","params":[3,1]}`, 0},
+		{"", `{"id":1,"method":"open"}`, 0},
+	} {
+		msg := call(tc.role, tc.body)
+		if (tc.code == 0 && msg.Error != nil) || (tc.code != 0 && (msg.Error == nil || msg.Error.Code != tc.code)) {
+			t.Fatalf("role=%q %s: error=%v want code %d", tc.role, tc.body, msg.Error, tc.code)
+		}
+	}
+}
+
+func TestServer_Methods(t *testing.T) {
+	s := newTestServer(WithDiscover(OpenRPCInfo{}, true))
+	s.Use("arith", func(c Context) { c.Next() })
+	s.Use("arith.add", func(c Context) { c.Next() })
+	methods := s.Methods()
+	if len(methods) != 4 || methods[0].Name != "arith.add" {
+		t.Fatalf("methods=%v", methods)
+	}
+	add := methods[0]
+	if !strings.HasSuffix(add.Func, "testArith).Add") || len(add.ArgTypes) != 2 || add.Middlewares != 2 {
+		t.Fatalf("add=%+v", add)
+	}
+	if sum := methods[3]; sum.Name != "arith.sum" || len(sum.ArgTypes) != 1 || sum.Middlewares != 1 {
+		t.Fatalf("sum=%+v", sum)
+	}
+}
+
+func TestServer_DisableEnable(t *testing.T) {
+	s := newTestServer()
+	call := func() *RPCMessage {
+		msg := &RPCMessage{}
+		if err := JSONDecode(doRequest(s, `{"id":1,"method":"arith.add","params":[1,2]}`).Body.Bytes(), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	if err := s.Disable("arith.missing"); err != ErrMethodNotFound {
+		t.Fatalf("err=%v", err)
+	}
+	if err := s.Disable("arith.add"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := call(); msg.Error == nil || msg.Error.Code != ErrDisabled || msg.Error.Message != "Method disabled" {
+		t.Fatalf("error=%v", msg.Error)
+	}
+	if !s.Methods()[0].Disabled {
+		t.Fatal("arith.add should be disabled")
+	}
+	_ = s.Disable("arith.add", NewError(ErrServer, "maintenance"))
+	if msg := call(); msg.Error == nil || msg.Error.Message != "maintenance" {
+		t.Fatalf("error=%v", msg.Error)
+	}
+	_ = s.Enable("arith.add")
+	if msg := call(); msg.Error != nil || string(msg.Result) != "3" {
+		t.Fatalf("error=%v result=%s", msg.Error, msg.Result)
+	}
+}
+
+func TestServer_StrictRegister(t *testing.T) {
+	s := newTestServer()
+	s.RegisterFunc("arith.add", func() {})
+	if err := s.Err(); err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	s = newTestServer(WithStrictRegister())
+	s.RegisterFunc("arith.add", func() {})
+	s.RegisterType(&testArith{}, "arith")
+	if err := s.Err(); !errors.Is(err, ErrDuplicateMethod) || !strings.Contains(err.Error(), "arith.add") {
+		t.Fatalf("err=%v want ErrDuplicateMethod", err)
+	}
+	msg := &RPCMessage{}
+	if err := JSONDecode(doRequest(s, `{"id":1,"method":"arith.add","params":[1,2]}`).Body.Bytes(), msg); err != nil || string(msg.Result) != "3" {
+		t.Fatalf("the first registration is replaced: %+v err=%v", msg, err)
+	}
+}
@@ -0,0 +1,180 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SSEConnHeader is the header carrying the connection id of the POST calls
+const SSEConnHeader = "X-J2rpc-Conn"
+
+var errSSEBufferFull = errors.New("sse buffer is full")
+
+// SSE serve the server-sent events connections, a GET request opens the event stream
+// and the first event "conn" carries the connection id. The calls are sent by POST with
+// the SSEConnHeader header (or the "conn" query), their notifications are pushed to the stream.
+type SSE struct {
+	Topics
+	server Server
+	option *SSEOption
+	mu     sync.RWMutex
+	conns  map[string]*sseConn
+}
+
+// SSEOption is the option of SSE
+type SSEOption struct {
+	// PingInterval is the interval of the keep-alive comments, default 30s
+	PingInterval time.Duration
+	// BufferSize is the number of the pending notifications of a connection, default 64
+	BufferSize int
+}
+
+type SSEOptionFunc func(option *SSEOption)
+
+// Conn return the connection by id
+func (e *SSE) Conn(id string) (Conn, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	conn, has := e.conns[id]
+	return conn, has
+}
+
+// Len return the number of connections
+func (e *SSE) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.conns)
+}
+
+func (e *SSE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		e.stream(w, r)
+		return
+	}
+	id := r.Header.Get(SSEConnHeader)
+	if id == "" {
+		id = r.URL.Query().Get("conn")
+	}
+	ctx := r.Context()
+	if conn, has := e.Conn(id); has {
+		ctx = context.WithValue(ctx, connContextKey{}, conn)
+	}
+	ctx, hooks := withActivationHooks(ctx)
+	defer hooks.run()
+	e.server.Handler(NewContext(ctx, w, r.WithContext(ctx)))
+}
+
+func (e *SSE) stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// the stream outlives the write timeout of the http server
+	_ = rc.SetWriteDeadline(time.Time{})
+	ctx, cancel := context.WithCancel(r.Context())
+	conn := &sseConn{id: uuid.NewString(), cancel: cancel, ch: make(chan []byte, e.option.BufferSize)}
+	conn.ctx = context.WithValue(ctx, connContextKey{}, conn)
+	e.mu.Lock()
+	e.conns[conn.id] = conn
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.conns, conn.id)
+		e.mu.Unlock()
+		cancel()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set(SSEConnHeader, conn.id)
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "event: conn\ndata: %q\n\n", conn.id); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	tk := time.NewTicker(e.option.PingInterval)
+	defer tk.Stop()
+	for {
+		var err error
+		select {
+		case <-conn.ctx.Done():
+			return
+		case data := <-conn.ch:
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		case <-tk.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type sseConn struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+	ch     chan []byte
+}
+
+func (c *sseConn) Close() error {
+	c.cancel()
+	return nil
+}
+
+func (c *sseConn) Context() context.Context { return c.ctx }
+
+func (c *sseConn) ID() string { return c.id }
+
+func (c *sseConn) Notify(method string, params interface{}) error {
+	data, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case c.ch <- bytes.TrimSpace(data):
+		return nil
+	default:
+		return errSSEBufferFull
+	}
+}
+
+// NewSSE create the server-sent events endpoint of the server
+func NewSSE(s Server, opts ...SSEOptionFunc) *SSE {
+	option := &SSEOption{PingInterval: 30 * time.Second, BufferSize: 64}
+	for _, opt := range opts {
+		opt(option)
+	}
+	return &SSE{server: s, option: option, conns: make(map[string]*sseConn)}
+}
+
+// WithSSEBufferSize set the number of the pending notifications of a connection
+func WithSSEBufferSize(n int) SSEOptionFunc {
+	return func(option *SSEOption) {
+		if n > 0 {
+			option.BufferSize = n
+		}
+	}
+}
+
+// WithSSEPingInterval set the interval of the keep-alive comments
+func WithSSEPingInterval(d time.Duration) SSEOptionFunc {
+	return func(option *SSEOption) {
+		if d > 0 {
+			option.PingInterval = d
+		}
+	}
+}
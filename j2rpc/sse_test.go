@@ -0,0 +1,53 @@
+package j2rpc
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSSE_Subscription(t *testing.T) {
+	s := NewServer()
+	s.RegisterType(testTicker{}, "ticker")
+	ts := httptest.NewServer(NewSSE(s))
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	connID := resp.Header.Get(SSEConnHeader)
+	if connID == "" {
+		t.Fatal("missing connection id")
+	}
+	reader := bufio.NewReader(resp.Body)
+	readData := func() string {
+		for {
+			line, e := reader.ReadString('\n')
+			if e != nil {
+				t.Fatal(e)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+			}
+		}
+	}
+	if data := readData(); data != `"`+connID+`"` {
+		t.Fatalf("first event=%s", data)
+	}
+	c := NewClient(NewHTTPTransport(ts.URL))
+	var id string
+	if err = c.Call(ContextWithHeader(ctx, SSEConnHeader, connID), "ticker.count", &id, 1); err != nil {
+		t.Fatal(err)
+	}
+	msg := &RPCMessage{}
+	if err = JSONDecode([]byte(readData()), msg); err != nil || msg.Method != "ticker_subscription" || !strings.Contains(string(msg.Params), id) {
+		t.Fatalf("unexpected notification: %+v", msg)
+	}
+}
@@ -0,0 +1,254 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotifierContextKey = "___j2rpc.notifier"
+
+	subscriptionSuffix = "_subscription"
+	unsubscribeSuffix  = "_unsubscribe"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+
+	errNotificationsUnsupported = NewError(ErrServer, "subscriptions require a long-lived connection")
+
+	subscriptionType = reflect.TypeOf((*Subscription)(nil))
+)
+
+type notifierContextKey struct{}
+
+// activationHooks delay the subscriptions until the response of the subscribe call is written,
+// so the client always receives the subscription id before the notifications
+type activationHooks struct {
+	mu   sync.Mutex
+	fns  []func()
+	done bool
+}
+
+func (h *activationHooks) add(fn func()) {
+	h.mu.Lock()
+	if !h.done {
+		h.fns = append(h.fns, fn)
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+	fn()
+}
+
+func (h *activationHooks) run() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns, h.done = nil, true
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+type activationContextKey struct{}
+
+// Notifier create the subscription of a subscribe call,
+// a method returning *Subscription get it by NotifierFromContext.
+type Notifier struct {
+	sub *Subscription
+}
+
+// CreateSubscription return the subscription of the call
+func (n *Notifier) CreateSubscription() *Subscription { return n.sub }
+
+// Notify send the data to the subscription
+func (n *Notifier) Notify(id string, data interface{}) error {
+	if id != n.sub.ID {
+		return ErrSubscriptionNotFound
+	}
+	return n.sub.Notify(data)
+}
+
+// Subscription is a stream of notifications over a long-lived connection,
+// it ends when the client unsubscribes or the connection is closed
+type Subscription struct {
+	ID        string
+	namespace string
+	conn      Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// SubscriptionResult is the params of the subscription notifications
+type SubscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// Context return the context which is canceled when the subscription ends
+func (s *Subscription) Context() context.Context { return s.ctx }
+
+// Done return a channel which is closed when the subscription ends
+func (s *Subscription) Done() <-chan struct{} { return s.ctx.Done() }
+
+// Notify send the data as {"method":"<namespace>_subscription","params":{"subscription":id,"result":data}},
+// it waits until the response of the subscribe call is written.
+func (s *Subscription) Notify(data interface{}) error {
+	select {
+	case <-s.ready:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.conn.Notify(s.namespace+subscriptionSuffix, SubscriptionResult{Subscription: s.ID, Result: data})
+}
+
+// Unsubscribe end the subscription from the server side
+func (s *Subscription) Unsubscribe() { s.cancel() }
+
+func (s *Subscription) activate() { s.readyOnce.Do(func() { close(s.ready) }) }
+
+// subscriptions is the registry of the active subscriptions of the server
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[string]*Subscription
+}
+
+func (s *subscriptions) add(sub *Subscription) {
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[string]*Subscription)
+	}
+	s.subs[sub.ID] = sub
+	s.mu.Unlock()
+	go func() {
+		<-sub.Done()
+		s.mu.Lock()
+		delete(s.subs, sub.ID)
+		s.mu.Unlock()
+	}()
+}
+
+func (s *subscriptions) get(id string) (*Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, has := s.subs[id]
+	return sub, has
+}
+
+// NotifierFromContext return the notifier of a subscribe call
+func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
+	if c, ok := ctx.(Context); ok {
+		if v, has := c.GetValue(NotifierContextKey); has {
+			n, ok1 := v.(*Notifier)
+			return n, ok1
+		}
+	}
+	n, ok := ctx.Value(notifierContextKey{}).(*Notifier)
+	return n, ok
+}
+
+// isSubscription report whether the method returns a *Subscription or a receive channel
+func (f funcInfo) isSubscription() bool {
+	out := f.resultTypes()
+	if len(out) == 0 {
+		return false
+	}
+	return out[0] == subscriptionType || (out[0].Kind() == reflect.Chan && out[0].ChanDir()&reflect.RecvDir != 0)
+}
+
+// namespace return the first segment of the method name
+func (f funcInfo) namespace() string {
+	namespace, _, _ := strings.Cut(f.name, Separator)
+	return namespace
+}
+
+// newSubscription create the subscription of the call, it lives as long as the connection
+func (s *server) newSubscription(c Context, f funcInfo) (*Subscription, error) {
+	conn, ok := ConnFromContext(c)
+	if !ok {
+		return nil, errNotificationsUnsupported
+	}
+	ctx, cancel := context.WithCancel(conn.Context())
+	sub := &Subscription{
+		ID:        uuid.NewString(),
+		namespace: f.namespace(),
+		conn:      conn,
+		cancel:    cancel,
+		ready:     make(chan struct{}),
+	}
+	notifier := &Notifier{sub: sub}
+	sub.ctx = context.WithValue(ctx, notifierContextKey{}, notifier)
+	c.SetValue(NotifierContextKey, notifier)
+	s.subs.add(sub)
+	return sub, nil
+}
+
+// pumpSubscription send the values received from the channel until the channel is closed
+// or the subscription ends
+func (s *server) pumpSubscription(sub *Subscription, ch reflect.Value) {
+	defer sub.Unsubscribe()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		if chosen == 0 || !ok {
+			return
+		}
+		if err := sub.Notify(v.Interface()); err != nil {
+			return
+		}
+	}
+}
+
+// registerUnsubscribe register the <namespace>_unsubscribe method once per namespace
+func (s *server) registerUnsubscribe(namespace string) {
+	name := namespace + unsubscribeSuffix
+	if _, has := s.router.funcs[name]; has {
+		return
+	}
+	s.RegisterFunc(name, s.unsubscribe)
+}
+
+// startSubscription activate the subscription after the response is written
+func (s *server) startSubscription(c Context, sub *Subscription, result reflect.Value) {
+	if result.Kind() == reflect.Chan {
+		go s.pumpSubscription(sub, result)
+	}
+	if hooks, ok := c.Value(activationContextKey{}).(*activationHooks); ok {
+		hooks.add(sub.activate)
+		return
+	}
+	sub.activate()
+}
+
+func (s *server) unsubscribe(ctx context.Context, id string) (bool, error) {
+	conn, ok := ConnFromContext(ctx)
+	if !ok {
+		return false, errNotificationsUnsupported
+	}
+	sub, has := s.subs.get(id)
+	if !has || sub.conn.ID() != conn.ID() {
+		return false, NewError(ErrBadParams, ErrSubscriptionNotFound.Error())
+	}
+	sub.Unsubscribe()
+	return true, nil
+}
+
+// withActivationHooks return the context delaying the subscriptions until hooks.run is called
+func withActivationHooks(ctx context.Context) (context.Context, *activationHooks) {
+	hooks := &activationHooks{}
+	return context.WithValue(ctx, activationContextKey{}, hooks), hooks
+}
@@ -0,0 +1,121 @@
+package j2rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TraceParentHeader is the W3C trace context header
+const TraceParentHeader = "traceparent"
+
+type spanContextKey struct{}
+
+// Span is the span of a call, it is exported when the call finishes
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Sampled  bool
+	Method   string
+	Start    time.Time
+	Duration time.Duration
+	// Code is the error code of the call, it is 0 when the call succeeds
+	Code ErrorCode
+}
+
+// SpanExporter receive the finished spans, e.g. send them to the tracing backend
+type SpanExporter func(span *Span)
+
+// TraceParent return the traceparent header of the span, it is sent with the downstream calls
+func (s *Span) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// SpanFromContext return the span of the call
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	span, ok := ctx.Value(spanContextKey{}).(*Span)
+	return span, ok
+}
+
+// Tracing return the middleware which continues the trace of the traceparent header
+// or starts a new one, the span is put in the context.Context of the method and exported when the call finishes,
+// e.g. s.Use("", Tracing(exporter))
+func Tracing(exporter SpanExporter) Handler {
+	return func(c Context) {
+		passNeutral(c)
+		begin, ok := callBegin(c)
+		rc, isRPC := c.(*rpcContext)
+		if !ok || !isRPC {
+			c.Next()
+			return
+		}
+		span := &Span{SpanID: randomHex(8), Sampled: true, Method: c.Msg().Method, Start: begin}
+		if c.Request() != nil {
+			if traceID, parentID, sampled, valid := parseTraceParent(c.Request().Header.Get(TraceParentHeader)); valid {
+				span.TraceID, span.ParentID, span.Sampled = traceID, parentID, sampled
+			}
+		}
+		if span.TraceID == "" {
+			span.TraceID = randomHex(16)
+		}
+		rc.Context = context.WithValue(rc.Context, spanContextKey{}, span)
+		c.Next()
+		span.Duration = time.Since(span.Start)
+		if msg := c.Msg(); msg.Error != nil {
+			span.Code = msg.Error.Code
+		}
+		if exporter != nil {
+			exporter(span)
+		}
+	}
+}
+
+// parseTraceParent parse the header "version-traceid-parentid-flags"
+func parseTraceParent(value string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		!isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return "", "", false, false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], flags[0]&0x01 == 1, true
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setTraceParentHeader continue the trace of the context in the downstream call
+func setTraceParentHeader(ctx context.Context, header http.Header) {
+	if header.Get(TraceParentHeader) != "" {
+		return
+	}
+	if span, ok := SpanFromContext(ctx); ok {
+		header.Set(TraceParentHeader, span.TraceParent())
+	}
+}
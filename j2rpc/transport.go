@@ -0,0 +1,77 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/glibtools/libs/gresty"
+)
+
+// Transport send the encoded request to the server and return the response body
+type Transport interface {
+	RoundTrip(ctx context.Context, header http.Header, body []byte) ([]byte, error)
+}
+
+// HTTPTransport send requests by http POST with gresty
+type HTTPTransport struct {
+	URL   string
+	Resty *gresty.Resty
+}
+
+func (t *HTTPTransport) RoundTrip(ctx context.Context, header http.Header, body []byte) ([]byte, error) {
+	client := gresty.Client()
+	if t.Resty != nil {
+		client = t.Resty.Client()
+	}
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeaderMultiValues(header).
+		SetBody(body).
+		Post(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	return checkTransportStatus(resp.StatusCode(), resp.Body())
+}
+
+// ServerTransport call the server in process through Server.ServeHTTP
+type ServerTransport struct {
+	Server Server
+}
+
+func (t *ServerTransport) RoundTrip(ctx context.Context, header http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	w := httptest.NewRecorder()
+	t.Server.ServeHTTP(w, req)
+	return checkTransportStatus(w.Code, w.Body.Bytes())
+}
+
+// checkTransportStatus convert the non-json responses to *Error with the http status code
+func checkTransportStatus(status int, body []byte) ([]byte, error) {
+	switch status {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, NewError(ErrorCode(status), string(bytes.TrimSpace(body)))
+	}
+}
+
+// NewHTTPTransport create the http transport, the default gresty client is used when r is omitted
+func NewHTTPTransport(url string, r ...*gresty.Resty) *HTTPTransport {
+	t := &HTTPTransport{URL: url}
+	if len(r) > 0 {
+		t.Resty = r[0]
+	}
+	return t
+}
+
+// NewServerTransport create the in-process transport of the server
+func NewServerTransport(s Server) *ServerTransport { return &ServerTransport{Server: s} }
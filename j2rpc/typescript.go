@@ -0,0 +1,304 @@
+package j2rpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const typeScriptHeader = `// Code generated by j2rpc; DO NOT EDIT.
+
+export interface RPCError {
+  code: number;
+  message: string;
+  data?: unknown;
+}
+
+export type Caller = (method: string, params?: unknown) => Promise<unknown>;
+
+/** httpCaller call the j2rpc server at url by fetch, the error responses are thrown as RPCError */
+export function httpCaller(url: string, init: RequestInit = {}): Caller {
+  let id = 0;
+  return async (method, params) => {
+    const res = await fetch(url, {
+      ...init,
+      method: "POST",
+      headers: { ...(init.headers as Record<string, string>), "Content-Type": "application/json" },
+      body: JSON.stringify({ jsonrpc: "2.0", id: ++id, method, params }),
+    });
+    if (!res.ok) {
+      throw { code: res.status, message: await res.text() } as RPCError;
+    }
+    const msg = await res.json();
+    if (msg.error) {
+      throw msg.error as RPCError;
+    }
+    return msg.result;
+  };
+}
+`
+
+// tsGenerator reflect go types to typescript types following the encoding/json rules,
+// named struct types are emitted as interfaces
+type tsGenerator struct {
+	defs  map[string]string
+	names map[reflect.Type]string
+}
+
+// GenerateTypeScript write the typescript module of the registered methods of the server,
+// it contains the interfaces of the go types, the params and result types and the RPCClient class
+// with a typed method per rpc method
+func GenerateTypeScript(s Server, w io.Writer) error {
+	srv, ok := s.(*server)
+	if !ok {
+		return errors.New("GenerateTypeScript: unsupported server")
+	}
+	g := &tsGenerator{defs: make(map[string]string), names: make(map[reflect.Type]string)}
+	names := make([]string, 0, len(srv.router.funcs))
+	for name := range srv.router.funcs {
+		if name != DiscoverMethod {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	types := bytes.NewBuffer(nil)
+	client := bytes.NewBuffer(nil)
+	client.WriteString("export class RPCClient {\n  constructor(private readonly call: Caller) {}\n")
+	for _, name := range names {
+		g.writeMethod(types, client, srv.router.funcs[name])
+	}
+	client.WriteString("}\n")
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(typeScriptHeader)
+	defNames := make([]string, 0, len(g.defs))
+	for name := range g.defs {
+		defNames = append(defNames, name)
+	}
+	sort.Strings(defNames)
+	for _, name := range defNames {
+		buf.WriteString("\n" + g.defs[name])
+	}
+	buf.WriteString("\n")
+	_, _ = types.WriteTo(buf)
+	buf.WriteString("\n")
+	_, _ = client.WriteTo(buf)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeMethod write the params and result types and the client method of f
+func (g *tsGenerator) writeMethod(types, client *bytes.Buffer, f funcInfo) {
+	typeName, ident := tsTypeName(f.name), tsIdentifier(f.name)
+	params := f.paramTypes()
+	result := "void"
+	if out := f.resultTypes(); f.isSubscription() {
+		result = "string"
+	} else if len(out) > 0 {
+		result = g.typeOf(out[0])
+	}
+
+	call := fmt.Sprintf("this.call(%s)", strconv.Quote(f.name))
+	signature := ""
+	switch {
+	case len(params) == 0:
+	case len(f.meta.ParamNames) > 0:
+		_, _ = fmt.Fprintf(types, "export interface %sParams {\n", typeName)
+		for i, typ := range params {
+			if i >= len(f.meta.ParamNames) {
+				break
+			}
+			optional := ""
+			if typ.Kind() == reflect.Ptr {
+				optional = "?"
+			}
+			_, _ = fmt.Fprintf(types, "  %s%s: %s;\n", tsPropertyName(f.meta.ParamNames[i]), optional, g.typeOf(typ))
+		}
+		types.WriteString("}\n")
+		signature = "params: " + typeName + "Params"
+		call = fmt.Sprintf("this.call(%s, params)", strconv.Quote(f.name))
+	case len(params) == 1 && isObjectType(params[0]):
+		_, _ = fmt.Fprintf(types, "export type %sParams = %s;\n", typeName, g.typeOf(params[0]))
+		signature = "params: " + typeName + "Params"
+		call = fmt.Sprintf("this.call(%s, params)", strconv.Quote(f.name))
+	default:
+		list := make([]string, 0, len(params))
+		for _, typ := range params {
+			list = append(list, g.typeOf(typ))
+		}
+		_, _ = fmt.Fprintf(types, "export type %sParams = [%s];\n", typeName, strings.Join(list, ", "))
+		signature = "...params: " + typeName + "Params"
+		call = fmt.Sprintf("this.call(%s, params)", strconv.Quote(f.name))
+	}
+	_, _ = fmt.Fprintf(types, "export type %sResult = %s;\n", typeName, result)
+	_, _ = fmt.Fprintf(client, "\n  /** %s */\n  %s(%s): Promise<%sResult> {\n    return %s as Promise<%sResult>;\n  }\n",
+		f.name, ident, signature, typeName, call, typeName)
+}
+
+// typeOf return the typescript type of the go type
+func (g *tsGenerator) typeOf(typ reflect.Type) string {
+	nullable := false
+	for typ.Kind() == reflect.Ptr {
+		typ, nullable = typ.Elem(), true
+	}
+	t := g.nonNullTypeOf(typ)
+	if nullable {
+		return t + " | null"
+	}
+	return t
+}
+
+func (g *tsGenerator) nonNullTypeOf(typ reflect.Type) string {
+	switch {
+	case typ == timeType:
+		return "string"
+	case typ == rawMessageType:
+		return "unknown"
+	case typ.Kind() != reflect.Struct && reflect.PointerTo(typ).Implements(textMarshalerType):
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			return "string"
+		}
+		elem := g.typeOf(typ.Elem())
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return "Record<string, " + g.typeOf(typ.Elem()) + ">"
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.structBody(typ, "")
+		}
+		name, has := g.names[typ]
+		if !has {
+			name = g.definitionName(typ)
+			g.names[typ] = name
+			// reserve the name first, the struct may refer to itself
+			g.defs[name] = ""
+			g.defs[name] = "export interface " + name + " " + g.structBody(typ, "") + "\n"
+		}
+		return name
+	default:
+		return "unknown"
+	}
+}
+
+func (g *tsGenerator) definitionName(typ reflect.Type) string {
+	name := sanitizeSchemaName(typ.Name())
+	if _, has := g.defs[name]; !has {
+		return name
+	}
+	pkg := typ.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	name = tsTypeName(pkg) + name
+	for i := 2; ; i++ {
+		if _, has := g.defs[name]; !has {
+			return name
+		}
+		name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+	}
+}
+
+// structBody return the object type of the struct following the encoding/json field rules,
+// the omitempty fields are optional
+func (g *tsGenerator) structBody(typ reflect.Type, indent string) string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("{\n")
+	g.writeFields(buf, typ, indent+"  ", make(map[string]struct{}))
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+func (g *tsGenerator) writeFields(buf *bytes.Buffer, typ reflect.Type, indent string, seen map[string]struct{}) {
+	for _, field := range reflect.VisibleFields(typ) {
+		if len(field.Index) > 1 {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		isEmbedded := field.Anonymous && name == "" && IndirectType(field.Type).Kind() == reflect.Struct
+		if !field.IsExported() && !isEmbedded {
+			continue
+		}
+		if isEmbedded {
+			g.writeFields(buf, IndirectType(field.Type), indent, seen)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, has := seen[name]; has {
+			continue
+		}
+		seen[name] = struct{}{}
+		t := g.typeOf(field.Type)
+		if strings.Contains(opts, "string") {
+			t = "string"
+		}
+		optional := ""
+		if strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero") {
+			optional = "?"
+		}
+		_, _ = fmt.Fprintf(buf, "%s%s%s: %s;\n", indent, tsPropertyName(name), optional, t)
+	}
+}
+
+// tsIdentifier convert the method name to the camel case identifier, e.g. user.getInfo => userGetInfo
+func tsIdentifier(name string) string {
+	typeName := tsTypeName(name)
+	if typeName == "" {
+		return "_"
+	}
+	runes := []rune(typeName)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// tsPropertyName quote the property name when it isn't an identifier
+func tsPropertyName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return strconv.Quote(name)
+		}
+	}
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+// tsTypeName convert the method name to the pascal case type name, e.g. user.getInfo => UserGetInfo
+func tsTypeName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		parts[i] = string(runes)
+	}
+	name = strings.Join(parts, "")
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
+	}
+	return name
+}
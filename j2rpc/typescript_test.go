@@ -0,0 +1,42 @@
+package j2rpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypeScript(t *testing.T) {
+	type profile struct {
+		Nick string `json:"nick"`
+	}
+	type user struct {
+		ID      int64    `json:"id"`
+		Name    string   `json:"name,omitempty"`
+		Tags    []string `json:"tags"`
+		Profile *profile `json:"profile"`
+		Secret  string   `json:"-"`
+	}
+	s := newTestServer()
+	s.RegisterFunc("user.get", func(id int64) (*user, error) { return nil, nil })
+	buf := bytes.NewBuffer(nil)
+	if err := GenerateTypeScript(s, buf); err != nil {
+		t.Fatal(err)
+	}
+	ts := buf.String()
+	for _, want := range []string{
+		"export interface user {\n  id: number;\n  name?: string;\n  tags: string[];\n  profile: profile | null;\n}\n",
+		"export type ArithAddParams = [number, number];\n",
+		"export interface ArithSubParams {\n  a: number;\n  b: number;\n}\n",
+		"export type UserGetResult = user | null;\n",
+		"  arithAdd(...params: ArithAddParams): Promise<ArithAddResult> {\n    return this.call(\"arith.add\", params) as Promise<ArithAddResult>;\n  }\n",
+		"  arithFail(): Promise<ArithFailResult> {\n",
+	} {
+		if !strings.Contains(ts, want) {
+			t.Fatalf("missing %q in:\n%s", want, ts)
+		}
+	}
+	if strings.Contains(ts, "rpc.discover") || strings.Contains(ts, "Secret") {
+		t.Fatalf("unexpected output:\n%s", ts)
+	}
+}
@@ -0,0 +1,40 @@
+package j2rpc
+
+import (
+	"reflect"
+	"strings"
+)
+
+// validateArguments validate the struct arguments with ServerOption.Validator,
+// the failures are returned as ErrBadParams with the []util.FieldError data
+func (s *server) validateArguments(values []reflect.Value) error {
+	v := s.option.Validator
+	if v == nil {
+		return nil
+	}
+	for _, value := range values {
+		for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				break
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			continue
+		}
+		err := v.Valid().Struct(value.Interface())
+		if err == nil {
+			continue
+		}
+		fields := v.TranslateFieldsZh(err)
+		if fields == nil {
+			return NewError(ErrBadParams, v.TranslateZh(err).Error())
+		}
+		messages := make([]string, 0, len(fields))
+		for _, field := range fields {
+			messages = append(messages, field.Message)
+		}
+		return NewError(ErrBadParams, strings.Join(messages, ","), fields)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket serve the json-rpc calls over websocket connections,
+// every message is handled through the same handler chain as http POST,
+// many calls can be in flight on one connection.
+// The messages aren't encrypted by WithCrypto, the CryptoHeader of the upgrade request doesn't apply to them.
+type WebSocket struct {
+	Topics
+	server   Server
+	option   *WebSocketOption
+	upgrader websocket.Upgrader
+	mu       sync.RWMutex
+	conns    map[string]*wsConn
+}
+
+// WebSocketOption is the option of WebSocket
+type WebSocketOption struct {
+	// ReadLimit is the max size of a message, default 5MB
+	ReadLimit int64
+	// PingInterval is the interval of the ping messages, default 30s
+	PingInterval time.Duration
+	// WriteTimeout is the timeout of writing a message, default 10s
+	WriteTimeout time.Duration
+	// Concurrency is the number of calls handled at the same time on a connection, default 16
+	Concurrency int
+	// CheckOrigin is the origin check of the upgrader, the origin must match the host when it is nil,
+	// e.g. WithWebSocketCheckOrigin to allow the cross-origin connections
+	CheckOrigin func(r *http.Request) bool
+}
+
+type WebSocketOptionFunc func(option *WebSocketOption)
+
+// Broadcast push the notification to every connection
+func (ws *WebSocket) Broadcast(method string, params interface{}) int {
+	ws.mu.RLock()
+	conns := make([]*wsConn, 0, len(ws.conns))
+	for _, conn := range ws.conns {
+		conns = append(conns, conn)
+	}
+	ws.mu.RUnlock()
+	n := 0
+	for _, conn := range conns {
+		if conn.Notify(method, params) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Conn return the connection by id
+func (ws *WebSocket) Conn(id string) (Conn, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	conn, has := ws.conns[id]
+	return conn, has
+}
+
+// Len return the number of connections
+func (ws *WebSocket) Len() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return len(ws.conns)
+}
+
+func (ws *WebSocket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wsc, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	conn := &wsConn{id: uuid.NewString(), cancel: cancel, ws: wsc, writeTimeout: ws.option.WriteTimeout}
+	conn.ctx = context.WithValue(ctx, connContextKey{}, conn)
+	ws.mu.Lock()
+	ws.conns[conn.id] = conn
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.conns, conn.id)
+		ws.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	pongWait := ws.option.PingInterval * 2
+	wsc.SetReadLimit(ws.option.ReadLimit)
+	_ = wsc.SetReadDeadline(time.Now().Add(pongWait))
+	wsc.SetPongHandler(func(string) error { return wsc.SetReadDeadline(time.Now().Add(pongWait)) })
+	go conn.pingLoop(ws.option.PingInterval)
+
+	sem := make(chan struct{}, ws.option.Concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		messageType, data, e := wsc.ReadMessage()
+		if e != nil {
+			return
+		}
+		_ = wsc.SetReadDeadline(time.Now().Add(pongWait))
+		if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ws.serveMessage(conn, r, data)
+		}()
+	}
+}
+
+// serveMessage handle the message as a http POST request and write back the response
+func (ws *WebSocket) serveMessage(conn *wsConn, r *http.Request, data []byte) {
+	ctx, hooks := withActivationHooks(conn.ctx)
+	defer hooks.run()
+	req := r.Clone(ctx)
+	req.Method = http.MethodPost
+	// the response is a websocket message, the encodings of the upgrade request don't apply to it
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("Content-Encoding")
+	// the crypto nonce of the upgrade request would be replayed by every message
+	req.Header.Del(CryptoHeader)
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	w := httptest.NewRecorder()
+	ws.server.Handler(NewContext(ctx, w, req))
+	body := w.Body.Bytes()
+	switch w.Code {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return
+	default:
+		msg := &RPCMessage{ID: RawMessage("null"), Version: "2.0", Error: NewError(ErrorCode(w.Code), string(bytes.TrimSpace(body)))}
+		body, _ = JSONEncode(msg)
+	}
+	if len(body) == 0 {
+		return
+	}
+	_ = conn.write(websocket.TextMessage, body)
+}
+
+type wsConn struct {
+	id           string
+	ctx          context.Context
+	cancel       context.CancelFunc
+	ws           *websocket.Conn
+	writeMu      sync.Mutex
+	writeTimeout time.Duration
+}
+
+func (c *wsConn) Close() error {
+	c.cancel()
+	return c.ws.Close()
+}
+
+func (c *wsConn) Context() context.Context { return c.ctx }
+
+func (c *wsConn) ID() string { return c.id }
+
+func (c *wsConn) Notify(method string, params interface{}) error {
+	data, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+func (c *wsConn) pingLoop(interval time.Duration) {
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-tk.C:
+			deadline := time.Now().Add(c.writeTimeout)
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				_ = c.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) write(messageType int, data []byte) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.ws.WriteMessage(messageType, data)
+}
+
+// NewWebSocket create the websocket endpoint of the server
+func NewWebSocket(s Server, opts ...WebSocketOptionFunc) *WebSocket {
+	option := &WebSocketOption{
+		ReadLimit:    maxRequestContentLength,
+		PingInterval: 30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		Concurrency:  16,
+	}
+	for _, opt := range opts {
+		opt(option)
+	}
+	return &WebSocket{
+		server:   s,
+		option:   option,
+		upgrader: websocket.Upgrader{CheckOrigin: option.CheckOrigin},
+		conns:    make(map[string]*wsConn),
+	}
+}
+
+// WithWebSocketConcurrency set the number of calls handled at the same time on a connection
+func WithWebSocketConcurrency(n int) WebSocketOptionFunc {
+	return func(option *WebSocketOption) {
+		if n > 0 {
+			option.Concurrency = n
+		}
+	}
+}
+
+// WithWebSocketCheckOrigin set the origin check of the upgrader, it replaces the same-origin check
+func WithWebSocketCheckOrigin(fn func(r *http.Request) bool) WebSocketOptionFunc {
+	return func(option *WebSocketOption) {
+		option.CheckOrigin = fn
+	}
+}
+
+// WithWebSocketPingInterval set the interval of the ping messages
+func WithWebSocketPingInterval(d time.Duration) WebSocketOptionFunc {
+	return func(option *WebSocketOption) {
+		if d > 0 {
+			option.PingInterval = d
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/glibtools/libs/crypto"
+)
+
+func TestWebSocket(t *testing.T) {
+	s := newTestServer()
+	ws := NewWebSocket(s)
+	s.RegisterFunc("watch", func(ctx context.Context, topic string) error {
+		conn, ok := ConnFromContext(ctx)
+		if !ok {
+			return errors.New("no connection")
+		}
+		ws.Join(topic, conn)
+		return conn.Notify("welcome", topic)
+	})
+	ts := httptest.NewServer(ws)
+	defer ts.Close()
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	read := func() *RPCMessage {
+		_, data, e := c.ReadMessage()
+		if e != nil {
+			t.Fatal(e)
+		}
+		msg := &RPCMessage{}
+		if e = JSONDecode(data, msg); e != nil {
+			t.Fatalf("%s: %v", data, e)
+		}
+		return msg
+	}
+
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]}`))
+	if msg := read(); string(msg.ID) != "1" || string(msg.Result) != "3" {
+		t.Fatalf("unexpected response: %+v", msg)
+	}
+
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"watch","params":["orders"]}`))
+	if msg := read(); msg.Method != "welcome" || string(msg.Params) != `"orders"` {
+		t.Fatalf("unexpected notification: %+v", msg)
+	}
+	if msg := read(); string(msg.ID) != "2" || msg.Error != nil {
+		t.Fatalf("unexpected response: %+v", msg)
+	}
+	if n := ws.Publish("orders", "orderUpdated", map[string]int{"id": 7}); n != 1 {
+		t.Fatalf("published=%d want=1", n)
+	}
+	if msg := read(); msg.Method != "orderUpdated" || string(msg.Params) != `{"id":7}` {
+		t.Fatalf("unexpected notification: %+v", msg)
+	}
+	if ws.Len() != 1 {
+		t.Fatalf("len=%d want=1", ws.Len())
+	}
+}
+
+func TestWebSocket_Compression(t *testing.T) {
+	s := newTestServer(WithCompression(16))
+	s.RegisterFunc("repeat", func(n int) string { return strings.Repeat("a", n) })
+	ts := httptest.NewServer(NewWebSocket(s))
+	defer ts.Close()
+	header := http.Header{}
+	header.Set("Accept-Encoding", "gzip, br, zstd")
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"repeat","params":[4096]}`))
+	messageType, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &RPCMessage{}
+	if messageType != websocket.TextMessage || JSONDecode(data, msg) != nil || len(msg.Result) != 4096+2 {
+		t.Fatalf("type=%d len=%d: the response isn't the plain json", messageType, len(data))
+	}
+}
+
+func TestWebSocket_CheckOrigin(t *testing.T) {
+	dial := func(opts ...WebSocketOptionFunc) error {
+		ts := httptest.NewServer(NewWebSocket(newTestServer(), opts...))
+		defer ts.Close()
+		header := http.Header{}
+		header.Set("Origin", "https://evil.example")
+		c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+		if err == nil {
+			_ = c.Close()
+		}
+		return err
+	}
+	if err := dial(); !errors.Is(err, websocket.ErrBadHandshake) {
+		t.Fatalf("cross-origin dial err=%v want ErrBadHandshake", err)
+	}
+	if err := dial(WithWebSocketCheckOrigin(func(*http.Request) bool { return true })); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testTicker struct{}
+
+func (testTicker) Count(ctx context.Context, n int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 1; i <= n; i++ {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch
+}
+
+func (testTicker) Echo(ctx context.Context, v string) (*Subscription, error) {
+	notifier, ok := NotifierFromContext(ctx)
+	if !ok {
+		return nil, errors.New("no notifier")
+	}
+	sub := notifier.CreateSubscription()
+	go func() { _ = notifier.Notify(sub.ID, v) }()
+	return sub, nil
+}
+
+func TestWebSocket_Subscription(t *testing.T) {
+	s := NewServer()
+	s.RegisterType(testTicker{}, "ticker")
+	ts := httptest.NewServer(NewWebSocket(s))
+	defer ts.Close()
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	read := func() *RPCMessage {
+		_, data, e := c.ReadMessage()
+		if e != nil {
+			t.Fatal(e)
+		}
+		msg := &RPCMessage{}
+		if e = JSONDecode(data, msg); e != nil {
+			t.Fatalf("%s: %v", data, e)
+		}
+		return msg
+	}
+
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"ticker.count","params":[2]}`))
+	response := read()
+	var id string
+	if err = JSONDecode(response.Result, &id); err != nil || id == "" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	for i := 1; i <= 2; i++ {
+		msg := read()
+		result := &SubscriptionResult{}
+		if e := JSONDecode(msg.Params, result); e != nil || msg.Method != "ticker_subscription" || result.Subscription != id {
+			t.Fatalf("unexpected notification: %+v", msg)
+		}
+		if v, _ := result.Result.(float64); int(v) != i {
+			t.Fatalf("result=%v want=%d", result.Result, i)
+		}
+	}
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"ticker_unsubscribe","params":["`+id+`"]}`))
+	if msg := read(); string(msg.Result) != "true" {
+		t.Fatalf("unexpected unsubscribe response: %+v", msg)
+	}
+
+	_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"ticker.echo","params":["hi"]}`))
+	if msg := read(); string(msg.ID) != "3" || msg.Error != nil {
+		t.Fatalf("unexpected response: %+v", msg)
+	}
+	if msg := read(); msg.Method != "ticker_subscription" || !strings.Contains(string(msg.Params), `"hi"`) {
+		t.Fatalf("unexpected notification: %+v", msg)
+	}
+
+	w := doRequest(s, `{"jsonrpc":"2.0","id":1,"method":"ticker.count","params":[2]}`)
+	msg := &RPCMessage{}
+	if err = JSONDecode(w.Body.Bytes(), msg); err != nil || msg.Error == nil || msg.Error.Code != ErrServer {
+		t.Fatalf("http subscribe should fail: %s", w.Body.String())
+	}
+}
+
+func TestWebSocket_Crypto(t *testing.T) {
+	pair, err := crypto.EEInc.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		opts []CryptoOptionFunc
+		code ErrorCode
+	}{
+		{"optional", nil, 0},
+		{"required", []CryptoOptionFunc{WithCryptoRequired()}, http.StatusBadRequest},
+	}
+	for _, v := range cases {
+		ts := httptest.NewServer(NewWebSocket(newTestServer(WithCrypto(pair.PrivateKey, &testStore{}, v.opts...))))
+		// the header of the upgrade request isn't applied to the messages
+		header := http.Header{}
+		header.Set(CryptoHeader, "0123456789abcdef0123456789abcdef."+strconv.FormatInt(time.Now().Unix(), 10)+".AAAAAAAAAAAAAAAA")
+		c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for i := 1; i <= 2; i++ {
+			_ = c.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"arith.add","params":[1,2]}`))
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := &RPCMessage{}
+			if err = JSONDecode(data, msg); err != nil {
+				t.Fatalf("%s: %s: %v", v.name, data, err)
+			}
+			var code ErrorCode
+			if msg.Error != nil {
+				code = msg.Error.Code
+			}
+			if code != v.code || (code == 0 && string(msg.Result) != "3") {
+				t.Fatalf("%s: message %d: %s", v.name, i, data)
+			}
+		}
+		_ = c.Close()
+		ts.Close()
+	}
+}
@@ -143,6 +143,12 @@ func (t *Token) expired() bool { return t.ExpiresAt < util.TimeNow().Unix() }
 
 func DefaultJWT() *JWT { return new(JWT).New() }
 
+// RPCAuthorizer return the j2rpc authorizer checking the user stored by Verify,
+// the user must implement j2rpc.ImplCallerPermissions
+func RPCAuthorizer() j2rpc.Authorizer {
+	return j2rpc.PermissionAuthorizer(j2rpc.ContextValueCaller(ContextUserKey))
+}
+
 func NewJWTWithStore(store ItfTokenStore) *JWT { return (&JWT{Store: store}).New() }
 
 func SetGlobalCryptoKey(key string) {
@@ -104,7 +104,7 @@ func (c *CurdParams) Delete(args ...any) (err error) {
 	if err = c.Values.ToBean(bean); err != nil {
 		return
 	}
-	if err = DB.Where("id = ?", idv).Take(bean).Error; err != nil {
+	if err = Primary(c.db().DB).Where("id = ?", idv).Take(bean).Error; err != nil {
 		return j2rpc.NewError(400, err.Error())
 	}
 	if c.BeforeCall != nil {
@@ -141,7 +141,7 @@ func (c *CurdParams) Update(args ...any) (err error) {
 	db := c.prepareDB(args...)
 
 	oldBeanData := util.NewValue(bean)
-	if err = dbWithWhere(db, c.Where).Where("id = ?", idUint64).Take(oldBeanData).Error; err != nil {
+	if err = dbWithWhere(Primary(db), c.Where).Where("id = ?", idUint64).Take(oldBeanData).Error; err != nil {
 		return j2rpc.NewError(400, err.Error())
 	}
 
@@ -214,7 +214,7 @@ func (c *CurdParams) Update(args ...any) (err error) {
 
 func (c *CurdParams) getModel() (interface{}, error) {
 	if c.Model == nil {
-		model, err := DB.GetFindModel(c.Table)
+		model, err := c.db().GetFindModel(c.Table)
 		if err != nil {
 			return nil, err
 		}
@@ -223,8 +223,17 @@ func (c *CurdParams) getModel() (interface{}, error) {
 	return c.Model, nil
 }
 
+// db return the database of the table, see BindModels
+func (c *CurdParams) db() *GormDB {
+	table := c.Table
+	if table == "" && c.Model != nil {
+		table = ModelTableName(c.Model)
+	}
+	return DBByTable(table)
+}
+
 func (c *CurdParams) prepareDB(args ...any) *gorm.DB {
-	db := DB.DB
+	db := c.db().DB
 	for _, _arg := range args {
 		switch _v := _arg.(type) {
 		case WrapperDBFunc:
@@ -263,7 +272,7 @@ func (f *FindByID) AppendEqCondition(str string) {
 
 // FindByID ...
 func (f *FindByID) FindByID() (result interface{}, err error) {
-	db := DB
+	db := DBByTable(f.TableName)
 	// get bean
 	if f.Dest == nil {
 		f.Dest, err = db.GetFindModel(f.TableName)
@@ -333,20 +342,23 @@ func (f *FindParams) AppendEqCondition(str string) {
 	f.Condition += " AND " + str
 }
 
-// FindResultWithModel get data
+// FindResultWithModel get data, the database of the table is used when tx is nil
 func (f *FindParams) FindResultWithModel(tx *gorm.DB) (result *FindResult, err error) {
 	if f.Dest == nil {
 		if f.Table == "" {
 			err = errors.New("params.Table is empty")
 			return
 		}
-		dest, e := DB.GetFindModel(f.Table)
+		dest, e := DBByTable(f.Table).GetFindModel(f.Table)
 		if e != nil {
 			err = e
 			return
 		}
 		f.Dest = dest
 	}
+	if tx == nil {
+		tx = DBByTable(ModelTableName(f.Dest)).DB
+	}
 
 	tx = f.prepareTx(tx)
 	// pagination
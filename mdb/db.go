@@ -1,6 +1,7 @@
 package mdb
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
@@ -34,6 +36,7 @@ var (
 )
 
 type DBOption struct {
+	// Type is mysql, pg/postgres or sqlite/sqlite3, the DB of sqlite is the file or :memory:
 	Type string `json:"type"`
 	Host string `json:"host"`
 	Port string `json:"port"`
@@ -54,28 +57,18 @@ type DBOption struct {
 	SkipCreateDB bool `json:"skipCreateDb,omitempty"`
 
 	Gm2cConfig *Gm2cConfig `json:"gm2c_config,omitempty"`
+
+	// Replicas are the read replicas of the primary, see DBReplica
+	Replicas []DBReplica `json:"replicas,omitempty"`
+	// ReplicaCheckSeconds is the interval of the replica health checks, default 10
+	ReplicaCheckSeconds int64 `json:"replica_check_seconds,omitempty"`
+
+	replicas *replicaSet
 }
 
 func (d *DBOption) DBInitiate() (db *gorm.DB, err error) {
-	dsn := d.parseDSN()
-	var dialectal gorm.Dialector
-	switch d.Type {
-	case "mysql":
-		dialectal = mysql.New(mysql.Config{
-			DriverName:               "mysql",
-			DSN:                      dsn,
-			DefaultStringSize:        256,
-			DefaultDatetimePrecision: &defaultDatetimePrecision,
-			DisableDatetimePrecision: true,
-			DontSupportRenameIndex:   true,
-		})
-	case "pg", "postgres":
-		dialectal = postgres.New(postgres.Config{DriverName: "pgx", DSN: dsn})
-	default:
-		panic("unknown db type")
-	}
 	gormConfig := &gorm.Config{Logger: d.GetLogger()}
-	db, err = gorm.Open(dialectal, gormConfig)
+	db, err = gorm.Open(d.dialector(d.parseDSN(), nil), gormConfig)
 	if err != nil {
 		return
 	}
@@ -83,14 +76,7 @@ func (d *DBOption) DBInitiate() (db *gorm.DB, err error) {
 	if err != nil {
 		return
 	}
-	// SetMaxOpenConns 设置打开数据库连接的最大数量
-	sqlDB.SetMaxOpenConns(clampInt(d.MaxOpenConns, 200, 2000))
-	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
-	sqlDB.SetMaxIdleConns(clampInt(d.MaxIdleConns, 50, 500))
-	// SetConnMaxIdleTime 设置空闲连接池中连接的最大空闲时间
-	sqlDB.SetConnMaxIdleTime(time.Duration(clampInt(int(d.MaxIdleTimeSeconds), 30, 3600)) * time.Second)
-	// SetConnMaxLifetime 设置了连接可复用的最大时间
-	sqlDB.SetConnMaxLifetime(time.Duration(clampInt(int(d.MaxLifetimeSeconds), 30, 3600)) * time.Second)
+	d.setConnPool(sqlDB)
 	if err = sqlDB.Ping(); err != nil {
 		return
 	}
@@ -107,6 +93,12 @@ func (d *DBOption) DBInitiate() (db *gorm.DB, err error) {
 		}
 	}
 
+	if len(d.Replicas) > 0 {
+		if err = d.useReplicas(db); err != nil {
+			return
+		}
+	}
+
 	log.Printf("db %s connected\n", d.DB)
 	return
 }
@@ -137,6 +129,30 @@ func (d *DBOption) getCacheStore() CacheStore {
 	}
 }
 
+// dialector return the gorm dialector of the dsn, the opened conn is used when it isn't nil,
+// e.g. the replicas which may be unreachable when they are opened
+func (d *DBOption) dialector(dsn string, conn gorm.ConnPool) gorm.Dialector {
+	switch d.Type {
+	case "mysql":
+		return mysql.New(mysql.Config{
+			DriverName:                "mysql",
+			DSN:                       dsn,
+			Conn:                      conn,
+			SkipInitializeWithVersion: conn != nil,
+			DefaultStringSize:         256,
+			DefaultDatetimePrecision:  &defaultDatetimePrecision,
+			DisableDatetimePrecision:  true,
+			DontSupportRenameIndex:    true,
+		})
+	case "pg", "postgres":
+		return postgres.New(postgres.Config{DriverName: "pgx", DSN: dsn, Conn: conn})
+	case "sqlite", "sqlite3":
+		return &sqlite.Dialector{DriverName: sqlite.DriverName, DSN: dsn, Conn: conn}
+	default:
+		panic("unknown db type")
+	}
+}
+
 // parseDSN ...
 func (d *DBOption) parseDSN() string {
 	switch d.Type {
@@ -154,6 +170,8 @@ sslmode=disable
 TimeZone=Asia/Shanghai`
 		dsn = util.TextTemplateMustParse(dsn, d)
 		return dsn
+	case "sqlite", "sqlite3":
+		return d.sqliteDSN()
 	default:
 		panic("unknown db type")
 	}
@@ -161,7 +179,9 @@ TimeZone=Asia/Shanghai`
 
 type GormDB struct {
 	*gorm.DB
-	opt *DBOption
+	opt    *DBOption
+	name   string
+	locker MigrationLocker
 
 	models map[string]interface{}
 
@@ -202,6 +222,9 @@ func (g *GormDB) Close() (err error) {
 	if err = g.CheckDBNil(); err != nil {
 		return
 	}
+	if g.opt != nil {
+		g.opt.replicas.close()
+	}
 	sqlDB, err := g.DB.DB()
 	if err != nil {
 		return
@@ -231,6 +254,9 @@ func (g *GormDB) DBInitializationWithViper(v *viper.Viper, modelBus interface{},
 	g.Initialize(opt)
 	g.RegModelBus(modelBus)
 	g.MigrateModels(migrate)
+	if g == DB {
+		InitDBsWithViper(v, migrate)
+	}
 }
 
 func (g *GormDB) DropDB() {
@@ -280,7 +306,8 @@ func (g *GormDB) Initialize(opt *DBOption) *GormDB {
 	return g
 }
 
-// MigrateModels ...
+// MigrateModels register the models, the models are auto migrated and the pending steps are applied when migrate is true,
+// see MigrateUp
 func (g *GormDB) MigrateModels(v ...interface{}) {
 	migrate := false
 	models := make([]interface{}, 0)
@@ -294,40 +321,29 @@ func (g *GormDB) MigrateModels(v ...interface{}) {
 		}
 		models = append(models, vi)
 	}
-	models = append(models, globalDBModels...)
+	for _, vi := range globalDBModels {
+		if g.ownsModel(vi) {
+			models = append(models, vi)
+		}
+	}
 	for _, vi := range models {
 		g.RegModel(vi)
 	}
 	if globalModelBus != nil {
-		g.RegModelBus(globalModelBus)
-	}
-	if len(g.models) == 0 {
-		return
+		bindModelBus(globalModelBus)
+		for _, model := range util.ObjectTagInstances(globalModelBus, "model") {
+			if g.ownsModel(model) {
+				g.RegModel(model)
+			}
+		}
 	}
-
-	if HasMigrateLockFile() || !migrate {
+	if !migrate || len(g.models) == 0 && len(g.migrations()) == 0 {
 		return
 	}
-
-	models = models[:0]
-	for _, model := range g.models {
-		models = append(models, model)
-	}
-
-	e := g.AutoMigrate(models...)
-	if e != nil {
-		log.Fatalf("GormDB AutoMigrate models error: %s", e.Error())
-	}
-	for _, model := range models {
-		if m, ok := model.(ItfModelInitializer); ok {
-			_e := g.initializeModel(m)
-			if _e != nil {
-				log.Fatalf("ItfModelInitializer data error: %s", _e.Error())
-			}
-		}
+	if _, e := g.MigrateUp(context.Background()); e != nil {
+		log.Fatalf("GormDB migrate error: %s", e.Error())
 	}
-	createMigrateLockFile()
-	log.Println("GormDB AutoMigrate models success")
+	log.Println("GormDB migrate success")
 }
 
 // ModelByTableName ...
@@ -359,16 +375,23 @@ func (g *GormDB) ModelTableName(model interface{}) (name string) {
 
 func (g *GormDB) Opt() *DBOption { return g.opt }
 
-// RegModel ...
+// Name return the name of the database in the registry, it is empty for DB
+func (g *GormDB) Name() string { return g.name }
+
+// RegModel register the model, the model bound to another database is skipped
 func (g *GormDB) RegModel(model interface{}) {
 	tableName := g.ModelTableName(model)
+	if name := boundDBName(tableName); name != "" && name != g.name {
+		return
+	}
 	if _, ok := g.models[tableName]; !ok {
 		g.models[tableName] = model
 	}
 }
 
-// RegModelBus ...
+// RegModelBus register the models of the bus, the field tag `model:"db:analytics"` bind the model to the named database
 func (g *GormDB) RegModelBus(bus interface{}) {
+	bindModelBus(bus)
 	models := util.ObjectTagInstances(bus, "model")
 	for _, model := range models {
 		g.RegModel(model)
@@ -389,6 +412,9 @@ func (g *GormDB) ViewModels() map[string]interface{} { return g.viewModels }
 
 // createDB create database
 func (g *GormDB) createDB() (err error) {
+	if isSqlite(g.opt.Type) {
+		return g.createSqliteDB()
+	}
 	db, err := g.openDefaultDB()
 	// create database
 	if err != nil {
@@ -420,6 +446,9 @@ func (g *GormDB) createDB() (err error) {
 }
 
 func (g *GormDB) dropDB() (err error) {
+	if isSqlite(g.opt.Type) {
+		return g.dropSqliteDB()
+	}
 	db, err := g.openDefaultDB()
 	// create database
 	if err != nil {
@@ -496,6 +525,8 @@ type ItfModelInitializer interface {
 }
 
 type argsTagModel struct {
+	// DB is the name of the database the model is bound to, see BindModels
+	DB string `json:"db,omitempty"`
 	// default false
 	AutoDelete bool `json:"auto_delete,omitempty"`
 	// default days:90
@@ -521,7 +552,7 @@ func (*argsTagModel) deleteByCount(db *gorm.DB, model interface{}, saveCount int
 	if saveCount <= 0 {
 		return
 	}
-	sq1 := `id <= (SELECT id FROM {{.table}} ORDER BY id DESC LIMIT 1 OFFSET {{.save_count}});`
+	sq1 := `id <= (SELECT id FROM {{.table}} ORDER BY id DESC LIMIT 1 OFFSET {{.save_count}})`
 	sq1 = util.TextTemplateMustParse(sq1, util.Map{
 		"table":      ModelTableName(model),
 		"save_count": saveCount,
@@ -683,8 +714,12 @@ func NewGormDB() *GormDB {
 	}
 }
 
-func NewOptionWithViper(v *viper.Viper) *DBOption {
-	dbMapValue := v.GetStringMapString("db")
+func NewOptionWithViper(v *viper.Viper) *DBOption { return NewOptionWithViperKey(v, "db") }
+
+// NewOptionWithViperKey create the option of the viper section, e.g. db.analytics,
+// the database name of a named section defaults to the name
+func NewOptionWithViperKey(v *viper.Viper, key string) *DBOption {
+	dbMapValue := v.GetStringMapString(key)
 	dbType := dbMapValue["type"]
 	if dbType == "" {
 		return nil
@@ -693,8 +728,13 @@ func NewOptionWithViper(v *viper.Viper) *DBOption {
 	if dbHost == "" {
 		dbHost = v.GetString("app.host")
 	}
+	sectionName := ""
+	if key != "db" {
+		sectionName = key[strings.LastIndex(key, ".")+1:]
+	}
 	dbName := util.GenericValueLoopNotZeroCheck[string](
 		dbMapValue["db"],
+		sectionName,
 		v.GetString("app.name"),
 		util.AppName,
 	)
@@ -714,7 +754,10 @@ func NewOptionWithViper(v *viper.Viper) *DBOption {
 		MaxIdleTimeSeconds: cast.ToInt64(dbMapValue["max_idle_time_seconds"]),
 		MaxLifetimeSeconds: cast.ToInt64(dbMapValue["max_lifetime_seconds"]),
 		SkipCreateDB:       cast.ToBool(dbMapValue["skip_create_db"]),
+
+		ReplicaCheckSeconds: cast.ToInt64(dbMapValue["replica_check_seconds"]),
 	}
+	_ = v.UnmarshalKey(key+".replicas", &opt.Replicas)
 	return opt
 }
 
@@ -770,6 +813,10 @@ func modelTagParse(tag string) *argsTagModel {
 		switch k {
 		case "auto_delete", "autoDelete":
 			ret.AutoDelete = true
+		case "db":
+			if len(kv) >= 2 {
+				ret.DB = strings.TrimSpace(kv[1])
+			}
 		case "save":
 			if len(kv) < 2 {
 				continue
@@ -8,8 +8,9 @@ import (
 
 // Compare this snippet from src/libs/mdb/curd.go:
 
+// FindRecords find the records of the database the table of val is bound to, see BindModels
 func FindRecords[T any](val T, call func(tx *gorm.DB) *gorm.DB, args ...interface{}) (sliceResult []T, err error) {
-	return FindRecordsWithDB(DB.DB, val, call, args...)
+	return FindRecordsWithDB(DBByTable(ModelTableName(val)).DB, val, call, args...)
 }
 
 // FindRecordsWithDB ...
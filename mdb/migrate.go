@@ -1,19 +1,417 @@
 package mdb
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/glibtools/libs/util"
 )
 
+// MigrationTable is the table of the applied migrations
+const MigrationTable = "schema_migrations"
+
+// the states of MigrationStatus
+const (
+	MigrationApplied = "applied"
+	MigrationPending = "pending"
+	// MigrationChanged is the applied migration whose checksum isn't the registered one
+	MigrationChanged = "changed"
+	// MigrationMissing is the applied migration which isn't registered
+	MigrationMissing = "missing"
+)
+
 const migrateLock = "migrate.lock"
 
-func HasMigrateLockFile() bool { return fileExists(migrateLockFilePath()) }
+var (
+	ErrMigrationChecksum = errors.New("the checksum of the applied migration is changed")
+	ErrMigrationNoDown   = errors.New("the migration can't be rolled back")
+
+	migrationsMu sync.RWMutex
+	// migrations are the registered migrations of all the databases
+	migrations = make([]*Migration, 0)
+)
+
+// Migration is a versioned step of the schema or the data, the steps of a database are applied in the order of
+// Version (then Module) and each step runs in a transaction with its record in MigrationTable.
+// The DDL statements of MySQL commit implicitly, so a failed MySQL step may be applied partly without its record,
+// keep such a step to one DDL statement or make it rerunnable (e.g. IF NOT EXISTS) and fix the schema by hand
+// before migrating again.
+// A step is Go (Up, Down) or SQL (UpSQL, DownSQL, the statements are separated by ";")
+type Migration struct {
+	// Module is the module registering the step, e.g. user
+	Module string
+	// Version orders the steps, e.g. 20260102150405 or 0001
+	Version string
+	Name    string
+	// DB is the name of the database, see RegisterDB, DB when it is empty
+	DB string
+
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+
+	UpSQL   string
+	DownSQL string
+
+	// Revision is hashed into the checksum with the SQL, change it when a Go step is changed on purpose
+	Revision string
+}
+
+// Checksum return the sha256 of the step, the applied step is verified with it
+func (m *Migration) Checksum() string {
+	h := sha256.New()
+	for _, s := range []string{m.Module, m.Version, m.UpSQL, m.DownSQL, m.Revision} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (m *Migration) String() string {
+	if m.Name == "" {
+		return m.Module + "/" + m.Version
+	}
+	return m.Module + "/" + m.Version + "_" + m.Name
+}
+
+func (m *Migration) canDown() bool { return m.Down != nil || strings.TrimSpace(m.DownSQL) != "" }
+
+func (m *Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	return execSQL(tx, m.DownSQL)
+}
+
+func (m *Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return execSQL(tx, m.UpSQL)
+}
+
+// MigrationStatus is the state of a migration in the database
+type MigrationStatus struct {
+	Module    string     `json:"module"`
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// schemaMigration is the record of the applied migration
+type schemaMigration struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	Module    string    `gorm:"size:64;notnull;uniqueIndex:uk_schema_migrations_version"`
+	Version   string    `gorm:"size:64;notnull;uniqueIndex:uk_schema_migrations_version"`
+	Name      string    `gorm:"size:191"`
+	Checksum  string    `gorm:"size:64;notnull"`
+	AppliedAt time.Time `gorm:"notnull"`
+}
+
+func (schemaMigration) TableName() string { return MigrationTable }
+
+// MigrateDown roll back the last applied steps of the database, steps <= 0 is 1
+func (g *GormDB) MigrateDown(ctx context.Context, steps int) (rolled []MigrationStatus, err error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	err = g.withMigrationLock(ctx, func() (err error) {
+		records, err := g.appliedMigrations()
+		if err != nil {
+			return
+		}
+		registered := migrationIndex(g.migrations())
+		for i := len(records) - 1; i >= 0 && len(rolled) < steps; i-- {
+			record := records[i]
+			m := registered[record.Module+"/"+record.Version]
+			if m == nil {
+				return fmt.Errorf("migration %s/%s isn't registered", record.Module, record.Version)
+			}
+			if m.Checksum() != record.Checksum {
+				return fmt.Errorf("%w: %s", ErrMigrationChecksum, m)
+			}
+			if !m.canDown() {
+				return fmt.Errorf("%w: %s", ErrMigrationNoDown, m)
+			}
+			err = g.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if e := m.down(tx); e != nil {
+					return e
+				}
+				return tx.Delete(&schemaMigration{}, record.ID).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s down: %w", m, err)
+			}
+			log.Printf("db %s migration %s rolled back\n", g.opt.DB, m)
+			rolled = append(rolled, MigrationStatus{Module: m.Module, Version: m.Version, Name: m.Name, State: MigrationPending})
+		}
+		return
+	})
+	return
+}
+
+// MigrateUp auto migrate the models and apply the pending steps of the database in the migration lock,
+// the changed applied steps fail it before anything is applied, the steps applied before a failed one are kept
+func (g *GormDB) MigrateUp(ctx context.Context) (applied []MigrationStatus, err error) {
+	err = g.withMigrationLock(ctx, func() (err error) {
+		records, err := g.appliedMigrations()
+		if err != nil {
+			return
+		}
+		done := make(map[string]*schemaMigration, len(records))
+		for _, record := range records {
+			done[record.Module+"/"+record.Version] = record
+		}
+		pending := make([]*Migration, 0)
+		for _, m := range g.migrations() {
+			record, has := done[m.Module+"/"+m.Version]
+			if !has {
+				pending = append(pending, m)
+				continue
+			}
+			if m.Checksum() != record.Checksum {
+				return fmt.Errorf("%w: %s", ErrMigrationChecksum, m)
+			}
+		}
+		if err = g.autoMigrateModels(); err != nil {
+			return
+		}
+		for _, m := range pending {
+			now := time.Now()
+			// the DDL of MySQL isn't rolled back with the transaction, see Migration
+			err = g.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if e := m.up(tx); e != nil {
+					return e
+				}
+				return tx.Create(&schemaMigration{
+					Module:    m.Module,
+					Version:   m.Version,
+					Name:      m.Name,
+					Checksum:  m.Checksum(),
+					AppliedAt: now,
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s up: %w", m, err)
+			}
+			log.Printf("db %s migration %s applied\n", g.opt.DB, m)
+			applied = append(applied, MigrationStatus{Module: m.Module, Version: m.Version, Name: m.Name, State: MigrationApplied, AppliedAt: &now})
+		}
+		return
+	})
+	return
+}
 
-func createMigrateLockFile() {
-	_ = os.MkdirAll(filepath.Dir(migrateLockFilePath()), 0755)
-	_ = os.WriteFile(migrateLockFilePath(), []byte("1"), 0644)
+// MigrationStatuses return the states of the registered and the applied migrations in order,
+// it doesn't change the schema, the migrations are pending when the migration table doesn't exist
+func (g *GormDB) MigrationStatuses() (statuses []MigrationStatus, err error) {
+	if err = g.CheckDBNil(); err != nil {
+		return
+	}
+	records, err := g.appliedMigrations()
+	if err != nil {
+		return
+	}
+	done := make(map[string]*schemaMigration, len(records))
+	for _, record := range records {
+		done[record.Module+"/"+record.Version] = record
+	}
+	for _, m := range g.migrations() {
+		status := MigrationStatus{Module: m.Module, Version: m.Version, Name: m.Name, State: MigrationPending}
+		if record, has := done[m.Module+"/"+m.Version]; has {
+			delete(done, m.Module+"/"+m.Version)
+			status.State = MigrationApplied
+			if m.Checksum() != record.Checksum {
+				status.State = MigrationChanged
+			}
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range records {
+		if _, missing := done[record.Module+"/"+record.Version]; missing {
+			statuses = append(statuses, MigrationStatus{
+				Module:    record.Module,
+				Version:   record.Version,
+				Name:      record.Name,
+				State:     MigrationMissing,
+				AppliedAt: &record.AppliedAt,
+			})
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return migrationLess(statuses[i], statuses[j]) })
+	return
+}
+
+// appliedMigrations return the records of the applied migrations in the order they are applied,
+// there is none when the migration table doesn't exist
+func (g *GormDB) appliedMigrations() (records []*schemaMigration, err error) {
+	if !Primary(g.DB).Migrator().HasTable(&schemaMigration{}) {
+		return
+	}
+	err = Primary(g.DB).Order("id").Find(&records).Error
+	return
+}
+
+// autoMigrateModels auto migrate the registered models and initialize their data
+func (g *GormDB) autoMigrateModels() (err error) {
+	if len(g.models) == 0 {
+		return
+	}
+	models := make([]interface{}, 0, len(g.models))
+	for _, model := range g.models {
+		models = append(models, model)
+	}
+	if err = g.AutoMigrate(models...); err != nil {
+		return fmt.Errorf("GormDB AutoMigrate models error: %w", err)
+	}
+	for _, model := range models {
+		if m, ok := model.(ItfModelInitializer); ok {
+			if err = g.initializeModel(m); err != nil {
+				return fmt.Errorf("ItfModelInitializer data error: %w", err)
+			}
+		}
+	}
+	return
+}
+
+// migrations return the registered migrations of the database in order
+func (g *GormDB) migrations() []*Migration {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	list := make([]*Migration, 0)
+	for _, m := range migrations {
+		if m.DB == g.name {
+			list = append(list, m)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Version != list[j].Version {
+			return list[i].Version < list[j].Version
+		}
+		return list[i].Module < list[j].Module
+	})
+	return list
+}
+
+// withMigrationLock run fn holding the migration lock of the database, the migration table is created first
+func (g *GormDB) withMigrationLock(ctx context.Context, fn func() error) (err error) {
+	if err = g.CheckDBNil(); err != nil {
+		return
+	}
+	unlock, err := g.migrationLocker().Lock(ctx, "migrate:"+g.opt.DSNMd5())
+	if err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer unlock()
+	if err = g.AutoMigrate(&schemaMigration{}); err != nil {
+		return
+	}
+	return fn()
+}
+
+// HasMigrateLockFile report whether the migrate.lock file of the former versions exists
+//
+// Deprecated: the applied migrations are recorded in MigrationTable
+func HasMigrateLockFile() bool { return fileExists(filepath.Join(util.RootDir(), "data", migrateLock)) }
+
+// RegisterMigrations register the steps of the module, the module is set to the steps,
+// the duplicate versions of the module and the steps without Up or UpSQL panic
+func RegisterMigrations(module string, steps ...Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	for i := range steps {
+		m := steps[i]
+		m.Module = module
+		if m.DB == DefaultDBName {
+			m.DB = ""
+		}
+		if m.Version == "" {
+			panic(fmt.Sprintf("migration of %s has no version", module))
+		}
+		if m.Up == nil && strings.TrimSpace(m.UpSQL) == "" {
+			panic(fmt.Sprintf("migration %s has no up", &m))
+		}
+		for _, v := range migrations {
+			if v.Module == m.Module && v.Version == m.Version {
+				panic(fmt.Sprintf("migration %s is registered twice", &m))
+			}
+		}
+		migrations = append(migrations, &m)
+	}
+}
+
+// RegisterSQLMigrations register the SQL steps of the module in the directory of fsys,
+// the files are <version>_<name>.up.sql and <version>_<name>.down.sql, e.g. 0001_create_users.up.sql
+func RegisterSQLMigrations(module, db string, fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	steps := make(map[string]*Migration)
+	versions := make([]string, 0)
+	for _, file := range files {
+		base := path.Base(file)
+		var up bool
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			base, up = strings.TrimSuffix(base, ".up.sql"), true
+		case strings.HasSuffix(base, ".down.sql"):
+			base = strings.TrimSuffix(base, ".down.sql")
+		default:
+			continue
+		}
+		version, name, _ := strings.Cut(base, "_")
+		m := steps[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name, DB: db}
+			steps[version] = m
+			versions = append(versions, version)
+		}
+		data, e := fs.ReadFile(fsys, file)
+		if e != nil {
+			return e
+		}
+		if up {
+			m.UpSQL = string(data)
+		} else {
+			m.DownSQL = string(data)
+		}
+	}
+	sort.Strings(versions)
+	list := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		if steps[version].UpSQL == "" {
+			return fmt.Errorf("migration %s/%s has no up file", module, version)
+		}
+		list = append(list, *steps[version])
+	}
+	RegisterMigrations(module, list...)
+	return nil
+}
+
+// execSQL execute the statements of the SQL one by one
+func execSQL(tx *gorm.DB, sql string) error {
+	for _, statement := range splitSQL(sql, tx.Dialector.Name() == "mysql") {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func fileExists(file string) bool {
@@ -21,4 +419,104 @@ func fileExists(file string) bool {
 	return err == nil || os.IsExist(err)
 }
 
-func migrateLockFilePath() string { return filepath.Join(util.RootDir(), "data", migrateLock) }
+func migrationIndex(list []*Migration) map[string]*Migration {
+	index := make(map[string]*Migration, len(list))
+	for _, m := range list {
+		index[m.Module+"/"+m.Version] = m
+	}
+	return index
+}
+
+func migrationLess(a, b MigrationStatus) bool {
+	if a.Version != b.Version {
+		return a.Version < b.Version
+	}
+	return a.Module < b.Module
+}
+
+// splitSQL split the SQL into the statements by ";", the quoted ";" and the comments are kept.
+// The quotes are ', ", ` and the dollar quotes of postgres ($$ or $tag$), the comments are -- and /* */,
+// the backslash escapes the quote when backslash is true, e.g. mysql
+func splitSQL(sql string, backslash bool) (statements []string) {
+	var (
+		b    strings.Builder
+		code bool
+	)
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" && code {
+			statements = append(statements, s)
+		}
+		b.Reset()
+		code = false
+	}
+	for i := 0; i < len(sql); {
+		end, comment := i+1, false
+		switch c := sql[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end = sqlQuoteEnd(sql, i, backslash && c != '`')
+		case c == '$':
+			if tag := sqlDollarTag(sql, i); tag != "" {
+				end = sqlIndexEnd(sql, i+len(tag), tag)
+			}
+		case strings.HasPrefix(sql[i:], "--"):
+			end, comment = sqlIndexEnd(sql, i, "\n"), true
+		case strings.HasPrefix(sql[i:], "/*"):
+			end, comment = sqlIndexEnd(sql, i+2, "*/"), true
+		case c == ';':
+			flush()
+			i++
+			continue
+		}
+		if !comment && strings.TrimSpace(sql[i:end]) != "" {
+			code = true
+		}
+		b.WriteString(sql[i:end])
+		i = end
+	}
+	flush()
+	return
+}
+
+// sqlDollarTag return the dollar quote tag at i, e.g. $$ or $body$, the positional params like $1 aren't tags
+func sqlDollarTag(sql string, i int) string {
+	if i > 0 && isSQLIdentByte(sql[i-1]) {
+		return ""
+	}
+	j := i + 1
+	for ; j < len(sql) && isSQLIdentByte(sql[j]) && sql[j] != '$'; j++ {
+		if j == i+1 && sql[j] >= '0' && sql[j] <= '9' {
+			return ""
+		}
+	}
+	if j < len(sql) && sql[j] == '$' {
+		return sql[i : j+1]
+	}
+	return ""
+}
+
+// sqlIndexEnd return the end of the first sep from the index, or the end of the SQL
+func sqlIndexEnd(sql string, from int, sep string) int {
+	if n := strings.Index(sql[from:], sep); n >= 0 {
+		return from + n + len(sep)
+	}
+	return len(sql)
+}
+
+// sqlQuoteEnd return the end of the quoted string at i, the doubled quote is a string followed by another
+func sqlQuoteEnd(sql string, i int, backslash bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if backslash {
+				j++
+			}
+		case sql[i]:
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isSQLIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= '0' && c <= '9') || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
@@ -0,0 +1,172 @@
+package mdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/glibtools/libs/util"
+)
+
+// migrationLockTimeout is the wait of the migration lock when the context has no deadline
+const migrationLockTimeout = 10 * time.Minute
+
+var (
+	ErrMigrationLocked = errors.New("the migration lock is held by another instance")
+
+	// localMigrationLocks serialize the migrations of the sqlite databases in the process
+	localMigrationLocks sync.Map
+)
+
+// MigrationLocker keep the instances from migrating a database concurrently
+type MigrationLocker interface {
+	// Lock wait for the lock of the key until ctx is done, unlock release it
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// dbMigrationLocker is the advisory lock of the database, GET_LOCK of mysql or pg_advisory_lock of postgres,
+// the sqlite databases are locked in the process
+type dbMigrationLocker struct {
+	g *GormDB
+}
+
+func (l *dbMigrationLocker) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	if isSqlite(l.g.opt.Type) {
+		mu, _ := localMigrationLocks.LoadOrStore(key, &sync.Mutex{})
+		mu.(*sync.Mutex).Lock()
+		return mu.(*sync.Mutex).Unlock, nil
+	}
+	ctx, cancel := migrationLockContext(ctx)
+	defer cancel()
+	sqlDB, err := l.g.DB.DB()
+	if err != nil {
+		return
+	}
+	// the advisory lock belongs to the session, so the connection is kept until unlock
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return
+	}
+	var (
+		release string
+		arg     interface{}
+	)
+	switch l.g.opt.Type {
+	case "mysql":
+		var got sql.NullInt64
+		deadline, _ := ctx.Deadline()
+		seconds := int(time.Until(deadline).Seconds())
+		if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, seconds).Scan(&got); err == nil && got.Int64 != 1 {
+			err = ErrMigrationLocked
+		}
+		release, arg = "SELECT RELEASE_LOCK(?)", key
+	default:
+		arg = advisoryLockID(key)
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", arg)
+		release = "SELECT pg_advisory_unlock($1)"
+	}
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), release, arg)
+		_ = conn.Close()
+	}, nil
+}
+
+// redisMigrationLocker is the lock of the key in redis, it is renewed until unlock
+type redisMigrationLocker struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+var redisUnlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+var redisRenewScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+
+func (l *redisMigrationLocker) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	ctx, cancel := migrationLockContext(ctx)
+	defer cancel()
+	token := util.UUIDString()
+	for {
+		var ok bool
+		if ok, err = l.client.SetNX(ctx, key, token, l.ttl).Result(); err != nil {
+			return
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ErrMigrationLocked
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = redisRenewScript.Run(context.Background(), l.client, []string{key}, token, l.ttl.Milliseconds()).Err()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			_ = redisUnlockScript.Run(context.Background(), l.client, []string{key}, token).Err()
+		})
+	}, nil
+}
+
+// SetMigrationLocker set the locker of the migrations, the advisory lock of the database is used by default
+func (g *GormDB) SetMigrationLocker(l MigrationLocker) *GormDB {
+	g.locker = l
+	return g
+}
+
+func (g *GormDB) migrationLocker() MigrationLocker {
+	if g.locker != nil {
+		return g.locker
+	}
+	return &dbMigrationLocker{g: g}
+}
+
+// NewRedisMigrationLocker create the migration locker with redis, e.g. the databases without the advisory lock,
+// the lock expires in ttl (default 30s) when the holder dies
+func NewRedisMigrationLocker(client *redis.Client, ttl ...time.Duration) MigrationLocker {
+	l := &redisMigrationLocker{client: client, ttl: 30 * time.Second}
+	if len(ttl) > 0 && ttl[0] > 0 {
+		l.ttl = ttl[0]
+	}
+	return l
+}
+
+// advisoryLockID return the bigint key of pg_advisory_lock
+func advisoryLockID(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// migrationLockContext set migrationLockTimeout when ctx has no deadline
+func migrationLockContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, has := ctx.Deadline(); has {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, migrationLockTimeout)
+}
@@ -0,0 +1,176 @@
+package mdb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/gorm"
+)
+
+func newMigrateTestDB(t *testing.T, steps ...Migration) *GormDB {
+	t.Helper()
+	resetRegistry(t)
+	g := RegisterDB("migrate_test", newSqliteMemoryDB(t))
+	migrationsMu.Lock()
+	registered := migrations
+	migrationsMu.Unlock()
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		migrations = registered
+		migrationsMu.Unlock()
+	})
+	for i := range steps {
+		steps[i].DB = "migrate_test"
+	}
+	RegisterMigrations("test", steps...)
+	return g
+}
+
+func migrationStates(t *testing.T, g *GormDB) (states []string) {
+	t.Helper()
+	statuses, err := g.MigrationStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		states = append(states, s.Version+":"+s.State)
+	}
+	return
+}
+
+func TestGormDB_MigrateUpDown(t *testing.T) {
+	g := newMigrateTestDB(t,
+		Migration{
+			Version: "0002",
+			Name:    "seed",
+			Up:      func(tx *gorm.DB) error { return tx.Exec("INSERT INTO items (name) VALUES ('a;b')").Error },
+			Down:    func(tx *gorm.DB) error { return tx.Exec("DELETE FROM items").Error },
+		},
+		Migration{
+			Version: "0001",
+			Name:    "create_items",
+			UpSQL:   "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);\n-- the index\nCREATE INDEX idx_items_name ON items (name);",
+			DownSQL: "DROP TABLE items;",
+		},
+	)
+	if got := migrationStates(t, g); !reflect.DeepEqual(got, []string{"0001:pending", "0002:pending"}) {
+		t.Fatalf("states = %v", got)
+	}
+	if g.Migrator().HasTable(MigrationTable) {
+		t.Fatal("the status created the migration table")
+	}
+
+	applied, err := g.MigrateUp(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Version != "0001" {
+		t.Fatalf("applied = %+v", applied)
+	}
+	var name string
+	if err = g.Raw("SELECT name FROM items").Scan(&name).Error; err != nil || name != "a;b" {
+		t.Fatalf("name = %q, err = %v", name, err)
+	}
+	if applied, err = g.MigrateUp(context.Background()); err != nil || len(applied) != 0 {
+		t.Fatalf("applied again = %+v, err = %v", applied, err)
+	}
+
+	rolled, err := g.MigrateDown(context.Background(), 1)
+	if err != nil || len(rolled) != 1 || rolled[0].Version != "0002" {
+		t.Fatalf("rolled = %+v, err = %v", rolled, err)
+	}
+	if got := migrationStates(t, g); !reflect.DeepEqual(got, []string{"0001:applied", "0002:pending"}) {
+		t.Fatalf("states = %v", got)
+	}
+	if _, err = g.MigrateDown(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+	if g.Migrator().HasTable("items") {
+		t.Fatal("items isn't dropped")
+	}
+}
+
+func TestGormDB_MigrateChecksum(t *testing.T) {
+	g := newMigrateTestDB(t, Migration{Version: "0001", UpSQL: "CREATE TABLE things (id INTEGER PRIMARY KEY)"})
+	if _, err := g.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	migrations[len(migrations)-1].UpSQL = "CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)"
+	if _, err := g.MigrateUp(context.Background()); !errors.Is(err, ErrMigrationChecksum) {
+		t.Fatalf("err = %v, want ErrMigrationChecksum", err)
+	}
+	if got := migrationStates(t, g); !reflect.DeepEqual(got, []string{"0001:changed"}) {
+		t.Fatalf("states = %v", got)
+	}
+}
+
+func TestRegisterSQLMigrations(t *testing.T) {
+	g := newMigrateTestDB(t)
+	fsys := fstest.MapFS{
+		"sql/0002_add_name.up.sql":   {Data: []byte("ALTER TABLE notes ADD COLUMN name TEXT;")},
+		"sql/0001_notes.up.sql":      {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY);")},
+		"sql/0001_notes.down.sql":    {Data: []byte("DROP TABLE notes;")},
+		"sql/README.md":              {Data: []byte("ignored")},
+		"sql/0002_add_name.down.sql": {Data: []byte("ALTER TABLE notes DROP COLUMN name;")},
+	}
+	if err := RegisterSQLMigrations("notes", "migrate_test", fsys, "sql"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Migrator().HasColumn("notes", "name") {
+		t.Fatal("notes.name isn't added")
+	}
+	if got := migrationStates(t, g); !reflect.DeepEqual(got, []string{"0001:applied", "0002:applied"}) {
+		t.Fatalf("states = %v", got)
+	}
+}
+
+func TestSplitSQL(t *testing.T) {
+	cases := []struct {
+		name      string
+		sql       string
+		backslash bool
+		want      []string
+	}{
+		{
+			name: "quotes and line comments",
+			sql:  "-- head\nCREATE TABLE a (x TEXT DEFAULT ';');\n\nINSERT INTO a VALUES (\"--;\"); -- tail\n",
+			want: []string{"-- head\nCREATE TABLE a (x TEXT DEFAULT ';')", "INSERT INTO a VALUES (\"--;\")"},
+		},
+		{
+			name: "block comments",
+			sql:  "/* a; 'b */ SELECT 1; /* only\n the comment; */;\nSELECT '/*;'",
+			want: []string{"/* a; 'b */ SELECT 1", "SELECT '/*;'"},
+		},
+		{
+			name: "dollar quotes",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\nDO $body$ BEGIN PERFORM '$$;'; END $body$; SELECT $1, a$b FROM t;",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql",
+				"DO $body$ BEGIN PERFORM '$$;'; END $body$",
+				"SELECT $1, a$b FROM t",
+			},
+		},
+		{
+			name:      "mysql backslash escapes",
+			sql:       `INSERT INTO a VALUES ('it\'s; ok', "say \"hi;\""); SELECT 1`,
+			backslash: true,
+			want:      []string{`INSERT INTO a VALUES ('it\'s; ok', "say \"hi;\"")`, "SELECT 1"},
+		},
+		{
+			name: "standard strings keep the backslash",
+			sql:  `SELECT 'C:\'; SELECT 'it''s;'`,
+			want: []string{`SELECT 'C:\'`, `SELECT 'it''s;'`},
+		},
+	}
+	for _, v := range cases {
+		if got := splitSQL(v.sql, v.backslash); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: splitSQL = %q, want %q", v.name, got, v.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package mdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// the actions of SchemaChange
+const (
+	PlanCreateTable = "create_table"
+	PlanAddColumn   = "add_column"
+	PlanAlterColumn = "alter_column"
+	PlanCreateIndex = "create_index"
+	PlanDropColumn  = "drop_column"
+	PlanDropTable   = "drop_table"
+)
+
+// MigrationPlan is the dry run of AutoMigrate, Statements are the SQL it would execute
+type MigrationPlan struct {
+	DB         string         `json:"db"`
+	Changes    []SchemaChange `json:"changes"`
+	Statements []string       `json:"statements"`
+}
+
+// Destructive return the changes which may lose the data
+func (p *MigrationPlan) Destructive() (changes []SchemaChange) {
+	for _, c := range p.Changes {
+		if c.Destructive {
+			changes = append(changes, c)
+		}
+	}
+	return
+}
+
+// Empty report whether the schema is up to date
+func (p *MigrationPlan) Empty() bool { return len(p.Changes) == 0 && len(p.Statements) == 0 }
+
+// SQL return the statements separated by ";"
+func (p *MigrationPlan) SQL() string {
+	var b strings.Builder
+	for _, s := range p.Statements {
+		b.WriteString(s)
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+// String return the human-readable plan, the destructive changes are listed at last
+func (p *MigrationPlan) String() string {
+	if p.Empty() {
+		return fmt.Sprintf("db %s: the schema is up to date\n", p.DB)
+	}
+	var b strings.Builder
+	destructive := p.Destructive()
+	_, _ = fmt.Fprintf(&b, "db %s: %d changes, %d destructive, %d statements\n",
+		p.DB, len(p.Changes)-len(destructive), len(destructive), len(p.Statements))
+	for _, c := range p.Changes {
+		if !c.Destructive {
+			b.WriteString("  " + c.String() + "\n")
+		}
+	}
+	if len(destructive) > 0 {
+		b.WriteString("destructive:\n")
+		for _, c := range destructive {
+			b.WriteString("  " + c.String() + "\n")
+		}
+	}
+	return b.String()
+}
+
+// SchemaChange is a difference of a model and the live schema, the destructive drops aren't applied by AutoMigrate,
+// the destructive alters (e.g. narrowing the type) are applied
+type SchemaChange struct {
+	Action      string `json:"action"`
+	Table       string `json:"table"`
+	Column      string `json:"column,omitempty"`
+	Index       string `json:"index,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Destructive bool   `json:"destructive,omitempty"`
+}
+
+func (c SchemaChange) String() string {
+	switch c.Action {
+	case PlanCreateTable:
+		return "+ create table " + c.Table
+	case PlanAddColumn:
+		return fmt.Sprintf("+ add column %s.%s %s", c.Table, c.Column, c.To)
+	case PlanAlterColumn:
+		s := fmt.Sprintf("~ alter column %s.%s %s -> %s", c.Table, c.Column, c.From, c.To)
+		if c.Destructive {
+			s = "! " + s[2:] + " (narrowing, applied by AutoMigrate)"
+		}
+		return s
+	case PlanCreateIndex:
+		return fmt.Sprintf("+ create index %s on %s", c.Index, c.Table)
+	case PlanDropColumn:
+		return fmt.Sprintf("- drop column %s.%s %s (not applied)", c.Table, c.Column, c.From)
+	case PlanDropTable:
+		return fmt.Sprintf("- drop table %s (not applied)", c.Table)
+	default:
+		return c.Action + " " + c.Table
+	}
+}
+
+// planConnPool record the executed statements of the migrator and pass the queries to the database
+type planConnPool struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	mu         sync.Mutex
+	statements []string
+}
+
+// BeginTx keep the recorder in the transactions of the migrator, e.g. the table recreation of sqlite
+func (p *planConnPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) { return p, nil }
+
+func (p *planConnPool) Commit() error { return nil }
+
+func (p *planConnPool) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !planSavepointRegexp.MatchString(query) {
+		p.mu.Lock()
+		p.statements = append(p.statements, strings.TrimSpace(p.dialector.Explain(query, args...)))
+		p.mu.Unlock()
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (p *planConnPool) Rollback() error { return nil }
+
+func (p *planConnPool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.statements)
+}
+
+var planSavepointRegexp = regexp.MustCompile(`(?i)^\s*(SAVEPOINT|RELEASE\s+SAVEPOINT|ROLLBACK\s+TO)\b`)
+
+// PlanMigration compare the registered models and the models with the live schema without changing it,
+// the plan has the changes and the SQL of AutoMigrate and the destructive differences it doesn't apply,
+// e.g. the dropped columns and tables
+func (g *GormDB) PlanMigration(models ...interface{}) (plan *MigrationPlan, err error) {
+	if err = g.CheckDBNil(); err != nil {
+		return
+	}
+	plan = &MigrationPlan{DB: g.opt.DB}
+	all := make(map[string]interface{}, len(g.models)+len(models))
+	for table, model := range g.models {
+		all[table] = model
+	}
+	for _, model := range models {
+		all[ModelTableName(model)] = model
+	}
+	tables := make([]string, 0, len(all))
+	for table := range all {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	// the statements of AutoMigrate are recorded, the column alters are detected with another recorder
+	record, probe := g.planDB(), g.planDB()
+	for _, table := range tables {
+		var changes []SchemaChange
+		if changes, err = g.planModel(probe, all[table]); err != nil {
+			return
+		}
+		plan.Changes = append(plan.Changes, changes...)
+		if err = record.Migrator().AutoMigrate(all[table]); err != nil {
+			return
+		}
+	}
+	plan.Statements = record.Statement.ConnPool.(*planConnPool).statements
+
+	live, err := g.Migrator().GetTables()
+	if err != nil {
+		return
+	}
+	sort.Strings(live)
+	for _, table := range live {
+		if _, has := all[table]; has || table == MigrationTable || strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		plan.Changes = append(plan.Changes, SchemaChange{Action: PlanDropTable, Table: table, Destructive: true})
+	}
+	return
+}
+
+// planDB return the session recording the statements in planConnPool
+func (g *GormDB) planDB() *gorm.DB {
+	tx := Primary(g.Session(&gorm.Session{NewDB: true, SkipDefaultTransaction: true, Logger: NewDBLoggerSilent()}))
+	tx.Statement.ConnPool = &planConnPool{ConnPool: g.DB.ConnPool, dialector: g.Dialector}
+	return tx
+}
+
+// planModel return the changes of the model, the alters of the columns are probed with MigrateColumn
+func (g *GormDB) planModel(probe *gorm.DB, model interface{}) (changes []SchemaChange, err error) {
+	stmt := &gorm.Statement{DB: g.DB}
+	if err = stmt.Parse(model); err != nil {
+		return
+	}
+	s, mig := stmt.Schema, probe.Migrator()
+	if !mig.HasTable(model) {
+		return []SchemaChange{{Action: PlanCreateTable, Table: s.Table}}, nil
+	}
+	columnTypes, err := mig.ColumnTypes(model)
+	if err != nil {
+		return
+	}
+	columns := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, ct := range columnTypes {
+		columns[strings.ToLower(ct.Name())] = ct
+	}
+	pool := probe.Statement.ConnPool.(*planConnPool)
+	for _, name := range s.DBNames {
+		field := s.FieldsByDBName[name]
+		if field.IgnoreMigration {
+			continue
+		}
+		to := planDataType(mig, field)
+		ct, has := columns[strings.ToLower(name)]
+		if !has {
+			changes = append(changes, SchemaChange{Action: PlanAddColumn, Table: s.Table, Column: name, To: to})
+			continue
+		}
+		delete(columns, strings.ToLower(name))
+		n := pool.len()
+		if err = mig.MigrateColumn(model, field, ct); err != nil {
+			return
+		}
+		if pool.len() == n {
+			continue
+		}
+		from := ct.DatabaseTypeName()
+		if full, ok := ct.ColumnType(); ok && full != "" {
+			from = full
+		}
+		changes = append(changes, SchemaChange{
+			Action:      PlanAlterColumn,
+			Table:       s.Table,
+			Column:      name,
+			From:        strings.ToLower(from),
+			To:          to,
+			Destructive: g.Dialector.Name() != "sqlite" && isNarrowing(ct, field, to),
+		})
+	}
+	for _, idx := range s.ParseIndexes() {
+		if !mig.HasIndex(model, idx.Name) {
+			changes = append(changes, SchemaChange{Action: PlanCreateIndex, Table: s.Table, Index: idx.Name})
+		}
+	}
+	dropped := make([]string, 0, len(columns))
+	for name := range columns {
+		dropped = append(dropped, name)
+	}
+	sort.Strings(dropped)
+	for _, name := range dropped {
+		changes = append(changes, SchemaChange{
+			Action:      PlanDropColumn,
+			Table:       s.Table,
+			Column:      columns[name].Name(),
+			From:        strings.ToLower(columns[name].DatabaseTypeName()),
+			Destructive: true,
+		})
+	}
+	return
+}
+
+// planTypeRanks are the widths of the types, the lower rank of the same family is narrowing
+var planTypeRanks = map[string][2]int{
+	"tinyint": {1, 1}, "smallint": {1, 2}, "int2": {1, 2}, "mediumint": {1, 3},
+	"int": {1, 4}, "integer": {1, 4}, "int4": {1, 4}, "bigint": {1, 8}, "int8": {1, 8},
+	"float": {2, 4}, "real": {2, 4}, "float4": {2, 4}, "double": {2, 8}, "double precision": {2, 8}, "float8": {2, 8},
+	"char": {3, 1}, "character": {3, 1}, "varchar": {3, 2}, "character varying": {3, 2},
+	"tinytext": {3, 3}, "text": {3, 4}, "mediumtext": {3, 5}, "longtext": {3, 6},
+}
+
+// isNarrowing report whether the column type to is narrower than the live column,
+// e.g. bigint to int, text to varchar or varchar(255) to varchar(64), the types of sqlite are affinities so they aren't compared
+func isNarrowing(ct gorm.ColumnType, field *schema.Field, to string) bool {
+	from, ok := planTypeRanks[planBaseType(ct.DatabaseTypeName())]
+	target, ok2 := planTypeRanks[planBaseType(to)]
+	if ok && ok2 && from[0] == target[0] && target[1] < from[1] {
+		return true
+	}
+	if length, has := ct.Length(); has && length > 0 && field.Size > 0 && int64(field.Size) < length &&
+		ok2 && target[0] == 3 && target[1] <= 2 {
+		return true
+	}
+	if precision, scale, has := ct.DecimalSize(); has && field.Precision > 0 &&
+		(int64(field.Precision) < precision || int64(field.Scale) < scale) {
+		return true
+	}
+	return false
+}
+
+// planBaseType return the type without the size and the modifiers, e.g. int for int(11) unsigned
+func planBaseType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		t = t[:i]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(t, " unsigned"))
+}
+
+// planDataType return the column type of the field in the database
+func planDataType(mig gorm.Migrator, field *schema.Field) string {
+	if m, ok := mig.(interface{ DataTypeOf(*schema.Field) string }); ok {
+		return strings.ToLower(m.DataTypeOf(field))
+	}
+	return strings.ToLower(mig.FullDataTypeOf(field).SQL)
+}
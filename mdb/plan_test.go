@@ -0,0 +1,103 @@
+package mdb
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+type planTestOrder struct {
+	ID   int    `gorm:"primaryKey"`
+	Code string `gorm:"size:32"`
+	Note string `gorm:"size:64;index"`
+}
+
+type planTestLine struct {
+	ID      int `gorm:"primaryKey"`
+	OrderID int
+}
+
+func TestGormDB_PlanMigration(t *testing.T) {
+	g := newMigrateTestDB(t)
+	err := g.Exec("CREATE TABLE plan_test_orders (id INTEGER PRIMARY KEY, code varchar(255), legacy TEXT)").Error
+	if err == nil {
+		err = g.Exec("CREATE TABLE stale_things (id INTEGER PRIMARY KEY)").Error
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := g.PlanMigration(&planTestOrder{}, &planTestLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(map[string]SchemaChange)
+	for _, c := range plan.Changes {
+		changes[c.Action+":"+c.Table+"."+c.Column+c.Index] = c
+	}
+	for key, destructive := range map[string]bool{
+		"create_table:plan_test_lines.":                           false,
+		"add_column:plan_test_orders.note":                        false,
+		"create_index:plan_test_orders.idx_plan_test_orders_note": false,
+		"alter_column:plan_test_orders.code":                      false,
+		"drop_column:plan_test_orders.legacy":                     true,
+		"drop_table:stale_things.":                                true,
+	} {
+		c, has := changes[key]
+		if !has {
+			t.Errorf("change %s isn't planned, plan:\n%s", key, plan)
+			continue
+		}
+		if c.Destructive != destructive {
+			t.Errorf("change %s destructive = %v", key, c.Destructive)
+		}
+	}
+
+	sql := plan.SQL()
+	for _, s := range []string{"CREATE TABLE `plan_test_lines`", "ALTER TABLE `plan_test_orders` ADD `note`", "CREATE INDEX `idx_plan_test_orders_note`"} {
+		if !strings.Contains(sql, s) {
+			t.Errorf("SQL hasn't %q:\n%s", s, sql)
+		}
+	}
+	if strings.Contains(sql, "legacy") && strings.Contains(sql, "DROP COLUMN") {
+		t.Errorf("SQL drops the column:\n%s", sql)
+	}
+	if !strings.Contains(plan.String(), "- drop column plan_test_orders.legacy") {
+		t.Errorf("unexpected text:\n%s", plan)
+	}
+	if g.Migrator().HasTable(&planTestLine{}) || g.Migrator().HasColumn(&planTestOrder{}, "note") {
+		t.Fatal("the plan changed the schema")
+	}
+}
+
+func TestIsNarrowing(t *testing.T) {
+	column := func(t string, length int64) migrator.ColumnType {
+		return migrator.ColumnType{
+			DataTypeValue:    sql.NullString{String: t, Valid: true},
+			LengthValue:      sql.NullInt64{Int64: length, Valid: true},
+			DecimalSizeValue: sql.NullInt64{Valid: true},
+		}
+	}
+	cases := []struct {
+		from   migrator.ColumnType
+		size   int
+		to     string
+		narrow bool
+	}{
+		{column("bigint", 0), 0, "int", true},
+		{column("int", 0), 0, "bigint", false},
+		{column("text", 0), 64, "varchar(64)", true},
+		{column("varchar", 255), 64, "varchar(64)", true},
+		{column("varchar", 64), 255, "varchar(255)", false},
+		{column("varchar", 255), 64, "text", false},
+		{column("double", 0), 0, "float", true},
+	}
+	for _, v := range cases {
+		if narrow := isNarrowing(v.from, &schema.Field{Size: v.size}, v.to); narrow != v.narrow {
+			t.Errorf("isNarrowing(%s(%d), %s) = %v", v.from.DataTypeValue.String, v.from.LengthValue.Int64, v.to, narrow)
+		}
+	}
+}
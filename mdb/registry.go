@@ -0,0 +1,159 @@
+package mdb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/glibtools/libs/util"
+)
+
+// DefaultDBName is the name of DB in the registry
+const DefaultDBName = "default"
+
+var (
+	registryMu sync.RWMutex
+	// namedDBs are the registered databases besides DB
+	namedDBs = make(map[string]*GormDB)
+	// modelDBNames are the names of the databases the tables are bound to
+	modelDBNames = make(map[string]string)
+	// boundModels are the models bound to the databases, they are registered with the database
+	boundModels = make(map[string][]interface{})
+)
+
+// ownsModel report whether the global model belongs to the database,
+// DB owns the unbound models and a named database owns the models bound to it
+func (g *GormDB) ownsModel(model interface{}) bool {
+	return boundDBName(ModelTableName(model)) == g.name
+}
+
+// BindModels bind the models to the named database, CurdParams, FindParams and FindByID of their tables use it,
+// the models are registered when the database is registered
+func BindModels(name string, models ...interface{}) {
+	if name == DefaultDBName {
+		name = ""
+	}
+	registryMu.Lock()
+	for _, model := range models {
+		table := ModelTableName(model)
+		if bound, has := modelDBNames[table]; has && bound == name {
+			continue
+		}
+		modelDBNames[table] = name
+		boundModels[name] = append(boundModels[name], model)
+	}
+	g := namedDBs[name]
+	registryMu.Unlock()
+	if g == nil {
+		return
+	}
+	for _, model := range models {
+		g.RegModel(model)
+	}
+}
+
+// DBByTable return the database the table is bound to, DB when it isn't bound or the database isn't registered
+func DBByTable(table string) *GormDB {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if g, has := namedDBs[modelDBNames[table]]; has {
+		return g
+	}
+	return DB
+}
+
+// DBNames return the names of the registered databases besides DefaultDBName
+func DBNames() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(namedDBs))
+	for name := range namedDBs {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// GetDB return the named database, DB is returned for DefaultDBName or the empty name
+func GetDB(name string) (*GormDB, bool) {
+	if name == "" || name == DefaultDBName {
+		return DB, true
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	g, has := namedDBs[name]
+	return g, has
+}
+
+// InitDBsWithViper initialize and register the named databases of the viper sections db.<name>.*,
+// a section is a database when it has the type, e.g. db.analytics.type
+func InitDBsWithViper(v *viper.Viper, migrate ...bool) {
+	for _, name := range viperDBNames(v) {
+		opt := NewOptionWithViperKey(v, "db."+name)
+		if opt == nil {
+			continue
+		}
+		g := RegisterDB(name, NewGormDB().Initialize(opt))
+		g.MigrateModels(len(migrate) > 0 && migrate[0])
+	}
+}
+
+// MustGetDB return the named database, it panics when the database isn't registered
+func MustGetDB(name string) *GormDB {
+	g, has := GetDB(name)
+	if !has {
+		panic(fmt.Sprintf("database %s isn't registered", name))
+	}
+	return g
+}
+
+// RegisterDB add the initialized named database, the models bound to the name are registered with it
+func RegisterDB(name string, g *GormDB) *GormDB {
+	if name == "" || name == DefaultDBName {
+		panic("the name of DB is reserved")
+	}
+	g.name = name
+	registryMu.Lock()
+	namedDBs[name] = g
+	models := boundModels[name]
+	registryMu.Unlock()
+	for _, model := range models {
+		g.RegModel(model)
+	}
+	return g
+}
+
+// bindModelBus bind the models of the bus having the tag `model:"db:<name>"`
+func bindModelBus(bus interface{}) {
+	val := util.ReflectIndirect(bus)
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		tags := modelTagParse(field.Tag.Get("model"))
+		if tags == nil || tags.DB == "" || field.Type.Kind() != reflect.Ptr {
+			continue
+		}
+		BindModels(tags.DB, reflect.New(field.Type.Elem()).Interface())
+	}
+}
+
+// boundDBName return the name of the database the table is bound to, it is empty for DB
+func boundDBName(table string) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return modelDBNames[table]
+}
+
+// viperDBNames return the names of the viper sections db.<name> having the type
+func viperDBNames(v *viper.Viper) []string {
+	names := make([]string, 0)
+	for name, val := range v.GetStringMap("db") {
+		if section, ok := val.(map[string]interface{}); ok && section["type"] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
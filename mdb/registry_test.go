@@ -0,0 +1,171 @@
+package mdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+type regTestOrder struct {
+	ID int `gorm:"primaryKey"`
+}
+
+type regTestEvent struct {
+	ID int `gorm:"primaryKey"`
+}
+
+type regTestNote struct {
+	ID int `gorm:"primaryKey"`
+}
+
+// resetRegistry restore the registry and DB after the test
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	dbs, names, models := namedDBs, modelDBNames, boundModels
+	namedDBs, modelDBNames, boundModels = make(map[string]*GormDB), make(map[string]string), make(map[string][]interface{})
+	registryMu.Unlock()
+	db := DB
+	t.Cleanup(func() {
+		registryMu.Lock()
+		namedDBs, modelDBNames, boundModels = dbs, names, models
+		registryMu.Unlock()
+		DB = db
+	})
+}
+
+func TestRegistry_BindModels(t *testing.T) {
+	resetRegistry(t)
+	DB = newSqliteMemoryDB(t)
+	orders := RegisterDB("reg_orders", newSqliteMemoryDB(t))
+	BindModels("reg_orders", &regTestOrder{})
+	BindModels("reg_events", &regTestEvent{})
+	BindModels(DefaultDBName, &regTestNote{})
+	events := RegisterDB("reg_events", newSqliteMemoryDB(t))
+
+	cases := []struct {
+		name  string
+		table string
+		bound string
+		db    *GormDB
+	}{
+		{"bound before the registration", ModelTableName(&regTestEvent{}), "reg_events", events},
+		{"bound after the registration", ModelTableName(&regTestOrder{}), "reg_orders", orders},
+		{"bound to DefaultDBName", ModelTableName(&regTestNote{}), "", DB},
+		{"unbound", "reg_test_unknowns", "", DB},
+	}
+	for _, v := range cases {
+		if got := boundDBName(v.table); got != v.bound {
+			t.Errorf("%s: boundDBName(%s) = %q want %q", v.name, v.table, got, v.bound)
+		}
+		if got := DBByTable(v.table); got != v.db {
+			t.Errorf("%s: DBByTable(%s) = %q want %q", v.name, v.table, got.Name(), v.db.Name())
+		}
+	}
+
+	// the bound models are registered with their database only
+	for g, want := range map[*GormDB]string{orders: "reg_test_orders", events: "reg_test_events"} {
+		if _, has := g.models[want]; !has || len(g.models) != 1 {
+			t.Errorf("%s models = %v want %s", g.Name(), reflect.ValueOf(g.models).MapKeys(), want)
+		}
+	}
+	DB.RegModel(&regTestOrder{})
+	DB.RegModel(&regTestNote{})
+	if _, has := DB.models["reg_test_orders"]; has {
+		t.Error("the model bound to reg_orders is registered with DB")
+	}
+	if _, has := DB.models["reg_test_notes"]; !has {
+		t.Error("the unbound model isn't registered with DB")
+	}
+	if !orders.ownsModel(&regTestOrder{}) || DB.ownsModel(&regTestOrder{}) || !DB.ownsModel(&regTestNote{}) {
+		t.Error("ownsModel doesn't follow the binding")
+	}
+}
+
+func TestRegistry_RegisterDB(t *testing.T) {
+	resetRegistry(t)
+	first := RegisterDB("reg_b", newSqliteMemoryDB(t))
+	RegisterDB("reg_a", newSqliteMemoryDB(t))
+	BindModels("reg_b", &regTestOrder{})
+	// the duplicate registration replaces the database and registers the bound models with it
+	second := RegisterDB("reg_b", newSqliteMemoryDB(t))
+
+	cases := []struct {
+		name string
+		db   string
+		want *GormDB
+		has  bool
+	}{
+		{"default", DefaultDBName, DB, true},
+		{"empty", "", DB, true},
+		{"duplicate", "reg_b", second, true},
+		{"missing", "reg_missing", nil, false},
+	}
+	for _, v := range cases {
+		if g, has := GetDB(v.db); g != v.want || has != v.has {
+			t.Errorf("%s: GetDB(%q) = %v, %v", v.name, v.db, g, has)
+		}
+	}
+	if second == first || second.Name() != "reg_b" || DBByTable("reg_test_orders") != second {
+		t.Fatal("the duplicate RegisterDB doesn't replace the database")
+	}
+	if _, has := second.models["reg_test_orders"]; !has {
+		t.Fatal("the bound model isn't registered with the replacement")
+	}
+	if got := DBNames(); !reflect.DeepEqual(got, []string{"reg_a", "reg_b"}) {
+		t.Fatalf("DBNames = %v", got)
+	}
+
+	for _, name := range []string{"", DefaultDBName} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterDB(%q) doesn't panic", name)
+				}
+			}()
+			RegisterDB(name, newSqliteMemoryDB(t))
+		}()
+	}
+	if MustGetDB("reg_a").Name() != "reg_a" {
+		t.Fatal("MustGetDB returns another database")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGetDB of the missing database doesn't panic")
+		}
+	}()
+	MustGetDB("reg_missing")
+}
+
+func TestInitDBsWithViper(t *testing.T) {
+	resetRegistry(t)
+	v := viper.New()
+	v.Set("db", map[string]interface{}{
+		"type":      "sqlite",
+		"reg_v2":    map[string]interface{}{"type": "sqlite", "db": sqliteMemory, "log_level": 1},
+		"reg_v1":    map[string]interface{}{"type": "sqlite", "db": sqliteMemory, "log_level": 1},
+		"no_type":   map[string]interface{}{"db": sqliteMemory},
+		"not_a_map": "x",
+	})
+	if got := viperDBNames(v); !reflect.DeepEqual(got, []string{"reg_v1", "reg_v2"}) {
+		t.Fatalf("viperDBNames = %v", got)
+	}
+
+	BindModels("reg_v1", &regTestEvent{})
+	InitDBsWithViper(v, true)
+	t.Cleanup(func() {
+		for _, name := range DBNames() {
+			_ = MustGetDB(name).Close()
+		}
+	})
+	if got := DBNames(); !reflect.DeepEqual(got, []string{"reg_v1", "reg_v2"}) {
+		t.Fatalf("DBNames = %v", got)
+	}
+	if g := MustGetDB("reg_v1"); g.CheckDBNil() != nil || !g.Migrator().HasTable(&regTestEvent{}) {
+		t.Fatal("the bound model of reg_v1 isn't migrated")
+	}
+	if MustGetDB("reg_v2").Migrator().HasTable(&regTestEvent{}) {
+		t.Fatal("the model of reg_v1 is migrated in reg_v2")
+	}
+}
@@ -0,0 +1,197 @@
+package mdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// DBReplica is a read replica of the primary database, the empty fields inherit the primary's.
+// The queries (Find, Count, Raw SELECT ...) are routed to the healthy replicas in turn,
+// the writes, the transactions and the queries with Primary stick to the primary,
+// the queries fall back to the primary when all the replicas are unhealthy.
+type DBReplica struct {
+	Host string `json:"host"`
+	Port string `json:"port"`
+	User string `json:"user"`
+	Pwd  string `json:"pwd"`
+}
+
+// replicaSet is the dbresolver policy choosing the healthy replicas
+type replicaSet struct {
+	primary  gorm.ConnPool
+	names    []string
+	pools    []*sql.DB
+	healthy  []atomic.Bool
+	next     atomic.Uint64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Resolve choose the next healthy replica, or the primary when there is none
+func (r *replicaSet) Resolve([]gorm.ConnPool) gorm.ConnPool {
+	n := uint64(len(r.pools))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if index := (start + i) % n; r.healthy[index].Load() {
+			return r.pools[index]
+		}
+	}
+	return r.primary
+}
+
+// check ping the replicas every interval until the set is closed
+func (r *replicaSet) check(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			for i := range r.pools {
+				r.ping(i)
+			}
+		}
+	}
+}
+
+func (r *replicaSet) close() {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		for _, pool := range r.pools {
+			_ = pool.Close()
+		}
+	})
+}
+
+// ping update the health of the replica, the changes are logged
+func (r *replicaSet) ping(i int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := r.pools[i].PingContext(ctx)
+	if healthy := err == nil; r.healthy[i].Swap(healthy) != healthy {
+		if healthy {
+			log.Printf("db replica %s recovered\n", r.names[i])
+		} else {
+			log.Printf("db replica %s is unhealthy: %s\n", r.names[i], err.Error())
+		}
+	}
+}
+
+// HealthyReplicas return the number of the healthy replicas and the replicas
+func (d *DBOption) HealthyReplicas() (healthy, total int) {
+	r := d.replicas
+	if r == nil {
+		return
+	}
+	for i := range r.healthy {
+		if r.healthy[i].Load() {
+			healthy++
+		}
+	}
+	return healthy, len(r.pools)
+}
+
+// replicaOption return the option of the replica, the empty fields inherit the primary's
+func (d *DBOption) replicaOption(replica DBReplica) *DBOption {
+	opt := *d
+	if replica.Host != "" {
+		opt.Host = replica.Host
+	}
+	if replica.Port != "" {
+		opt.Port = replica.Port
+	}
+	if replica.User != "" {
+		opt.User = replica.User
+	}
+	if replica.Pwd != "" {
+		opt.Pwd = replica.Pwd
+	}
+	return &opt
+}
+
+// setConnPool set the pool of the connections
+func (d *DBOption) setConnPool(sqlDB *sql.DB) {
+	if isSqlite(d.Type) {
+		d.setSqliteConnPool(sqlDB)
+		return
+	}
+	// SetMaxOpenConns 设置打开数据库连接的最大数量
+	sqlDB.SetMaxOpenConns(clampInt(d.MaxOpenConns, 200, 2000))
+	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
+	sqlDB.SetMaxIdleConns(clampInt(d.MaxIdleConns, 50, 500))
+	// SetConnMaxIdleTime 设置空闲连接池中连接的最大空闲时间
+	sqlDB.SetConnMaxIdleTime(time.Duration(clampInt(int(d.MaxIdleTimeSeconds), 30, 3600)) * time.Second)
+	// SetConnMaxLifetime 设置了连接可复用的最大时间
+	sqlDB.SetConnMaxLifetime(time.Duration(clampInt(int(d.MaxLifetimeSeconds), 30, 3600)) * time.Second)
+}
+
+// useReplicas open the replicas and register the dbresolver routing the queries to them,
+// an unreachable replica is kept unhealthy until the health check succeeds
+func (d *DBOption) useReplicas(db *gorm.DB) (err error) {
+	r := &replicaSet{
+		primary: db.ConnPool,
+		healthy: make([]atomic.Bool, len(d.Replicas)),
+		stop:    make(chan struct{}),
+	}
+	dialectors := make([]gorm.Dialector, 0, len(d.Replicas))
+	for _, replica := range d.Replicas {
+		opt := d.replicaOption(replica)
+		var sqlDB *sql.DB
+		if sqlDB, err = sql.Open(driverName(d.Type), opt.parseDSN()); err != nil {
+			r.close()
+			return
+		}
+		opt.setConnPool(sqlDB)
+		r.names = append(r.names, opt.Host+":"+opt.Port)
+		r.pools = append(r.pools, sqlDB)
+		dialectors = append(dialectors, d.dialector("", sqlDB))
+	}
+	for i := range r.pools {
+		r.healthy[i].Store(true)
+		r.ping(i)
+	}
+	// dbresolver skips the policy of a single replica, so it wouldn't fall back to the primary
+	if len(dialectors) == 1 {
+		dialectors = append(dialectors, d.dialector("", r.pools[0]))
+	}
+	if err = db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: r})); err != nil {
+		r.close()
+		return
+	}
+	interval := time.Duration(d.ReplicaCheckSeconds) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go r.check(interval)
+	d.replicas = r
+	return
+}
+
+// Primary route the queries of db to the primary, e.g. the reads before a write
+func Primary(db *gorm.DB) *gorm.DB { return db.Clauses(dbresolver.Write) }
+
+// Replica route the queries of db to the replicas
+func Replica(db *gorm.DB) *gorm.DB { return db.Clauses(dbresolver.Read) }
+
+// driverName return the database/sql driver name of the db type
+func driverName(dbType string) string {
+	switch dbType {
+	case "pg", "postgres":
+		return "pgx"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return dbType
+	}
+}
@@ -0,0 +1,164 @@
+package mdb
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// openReplicaTestPool open the sqlite file of the dir, the ping fails until the dir exists
+func openReplicaTestPool(t *testing.T, dir, name string) *sql.DB {
+	t.Helper()
+	opt := &DBOption{Type: "sqlite", DB: filepath.Join(dir, name)}
+	pool, err := sql.Open(driverName(opt.Type), opt.sqliteDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.setConnPool(pool)
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func seedReplicaTestPool(t *testing.T, pool *sql.DB, name string) {
+	t.Helper()
+	if _, err := pool.Exec("CREATE TABLE nodes (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec("INSERT INTO nodes (name) VALUES (?)", name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicaSet_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	primary := openReplicaTestPool(t, dir, "primary")
+	r1 := openReplicaTestPool(t, dir, "r1")
+	r2 := openReplicaTestPool(t, dir, "down/r2")
+	seedReplicaTestPool(t, primary, "primary")
+	seedReplicaTestPool(t, r1, "r1")
+
+	r := &replicaSet{
+		primary: primary,
+		names:   []string{"r1", "r2"},
+		pools:   []*sql.DB{r1, r2},
+		healthy: make([]atomic.Bool, 2),
+		stop:    make(chan struct{}),
+	}
+	opt := &DBOption{Type: "sqlite", replicas: r}
+	db, err := gorm.Open(opt.dialector("", primary), &gorm.Config{Logger: NewDBLoggerSilent()})
+	if err == nil {
+		// the policy chooses from r.pools, the dialector of the unreachable r2 would fail to initialize
+		err = db.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: []gorm.Dialector{opt.dialector("", r1), opt.dialector("", r1)},
+			Policy:   r,
+		}))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(tx *gorm.DB) (name string) {
+		t.Helper()
+		if err := tx.Raw("SELECT name FROM nodes").Scan(&name).Error; err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for i := range r.pools {
+		r.healthy[i].Store(true)
+		r.ping(i)
+	}
+
+	cases := []struct {
+		name    string
+		tx      func() *gorm.DB
+		want    string
+		healthy int
+	}{
+		{"the replica marked down is skipped", func() *gorm.DB { return db }, "r1", 1},
+		{"Replica", func() *gorm.DB { return Replica(db) }, "r1", 1},
+		{"Primary", func() *gorm.DB { return Primary(db) }, "primary", 1},
+		{"fallback to the primary", func() *gorm.DB { r.healthy[0].Store(false); return db }, "primary", 0},
+		{"fallback of Replica", func() *gorm.DB { return Replica(db) }, "primary", 0},
+	}
+	for _, v := range cases {
+		tx := v.tx()
+		if got := read(tx); got != v.want {
+			t.Fatalf("%s: read %s want %s", v.name, got, v.want)
+		}
+		if healthy, total := opt.HealthyReplicas(); healthy != v.healthy || total != 2 {
+			t.Fatalf("%s: healthy %d/%d want %d/2", v.name, healthy, total, v.healthy)
+		}
+	}
+
+	// the health check recovers the replicas
+	if err = os.MkdirAll(filepath.Join(dir, "down"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	go r.check(10 * time.Millisecond)
+	defer r.close()
+	deadline := time.Now().Add(5 * time.Second)
+	for healthy, _ := opt.HealthyReplicas(); healthy != 2; healthy, _ = opt.HealthyReplicas() {
+		if time.Now().After(deadline) {
+			t.Fatalf("replicas aren't recovered: %d healthy", healthy)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	seedReplicaTestPool(t, r2, "r2")
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		seen[read(db)] = true
+	}
+	if !seen["r1"] || !seen["r2"] || seen["primary"] {
+		t.Fatalf("the reads aren't balanced on the replicas: %v", seen)
+	}
+}
+
+func TestDBOption_Replicas(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGormDB().Initialize(&DBOption{
+		Type:     "sqlite",
+		DB:       filepath.Join(dir, "main"),
+		Logger:   NewDBLoggerSilent(),
+		Replicas: []DBReplica{{}},
+	})
+	if err := g.CheckDBNil(); err != nil {
+		t.Fatal(err)
+	}
+	if healthy, total := g.opt.HealthyReplicas(); healthy != 1 || total != 1 {
+		t.Fatalf("healthy %d/%d want 1/1", healthy, total)
+	}
+	if err := g.Exec("CREATE TABLE nodes (name TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Exec("INSERT INTO nodes (name) VALUES ('a')").Error; err != nil {
+		t.Fatal(err)
+	}
+	count := func() (n int64) {
+		t.Helper()
+		if err := Replica(g.DB).Raw("SELECT count(*) FROM nodes").Scan(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("count=%d want 1", n)
+	}
+	// the single replica is down, the reads fall back to the primary
+	_ = g.opt.replicas.pools[0].Close()
+	g.opt.replicas.ping(0)
+	if healthy, _ := g.opt.HealthyReplicas(); healthy != 0 {
+		t.Fatalf("healthy %d want 0", healthy)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("count=%d want 1", n)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
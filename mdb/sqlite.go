@@ -0,0 +1,79 @@
+package mdb
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/glibtools/libs/util"
+)
+
+// sqliteMemory is the DB of the in-memory sqlite database, e.g. the tests
+const sqliteMemory = ":memory:"
+
+// isMemory report whether the option is the in-memory sqlite database
+func (d *DBOption) isMemory() bool {
+	return isSqlite(d.Type) && (d.DB == sqliteMemory || d.DB == "memory")
+}
+
+// setSqliteConnPool set the pool of the sqlite connections, the in-memory database lives in one connection
+// and the file database is written by one connection at a time, so the pool is small
+func (d *DBOption) setSqliteConnPool(sqlDB *sql.DB) {
+	if d.isMemory() {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxIdleTime(0)
+		sqlDB.SetConnMaxLifetime(0)
+		return
+	}
+	sqlDB.SetMaxOpenConns(clampInt(d.MaxOpenConns, 1, 16))
+	sqlDB.SetMaxIdleConns(clampInt(d.MaxIdleConns, 1, 16))
+}
+
+// sqliteDSN return the dsn of the pure go sqlite driver, the file database uses WAL and waits for the locks
+func (d *DBOption) sqliteDSN() string {
+	if d.isMemory() {
+		return sqliteMemory + "?_pragma=foreign_keys(1)"
+	}
+	return "file:" + d.sqlitePath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+}
+
+// sqlitePath return the file of the sqlite database, a relative DB is in the data directory,
+// the extension .db is added when the DB has none
+func (d *DBOption) sqlitePath() string {
+	path := d.DB
+	if filepath.Ext(path) == "" {
+		path += ".db"
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(util.RootDir(), "data", path)
+	}
+	return path
+}
+
+// createSqliteDB create the directory of the sqlite file, the file is created when it is opened
+func (g *GormDB) createSqliteDB() error {
+	if g.opt.isMemory() {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(g.opt.sqlitePath()), 0755)
+}
+
+// dropSqliteDB remove the sqlite file and its WAL files
+func (g *GormDB) dropSqliteDB() (err error) {
+	if g.opt.isMemory() {
+		return
+	}
+	path := g.opt.sqlitePath()
+	for _, file := range []string{path, path + "-wal", path + "-shm"} {
+		if e := os.Remove(file); e != nil && !os.IsNotExist(e) {
+			err = e
+		}
+	}
+	return
+}
+
+// isSqlite report whether the db type is sqlite
+func isSqlite(dbType string) bool {
+	return dbType == "sqlite" || dbType == "sqlite3"
+}
@@ -0,0 +1,111 @@
+package mdb
+
+import (
+	"testing"
+
+	"github.com/glibtools/libs/util"
+)
+
+type liteTestUser struct {
+	BaseModel
+	Name string `json:"name" gorm:"size:64;index"`
+}
+
+// newSqliteMemoryDB return a sqlite memory database closed after the test
+func newSqliteMemoryDB(t *testing.T) *GormDB {
+	t.Helper()
+	g := NewGormDB().Initialize(&DBOption{Type: "sqlite", DB: sqliteMemory, Logger: NewDBLoggerSilent()})
+	t.Cleanup(func() { _ = g.Close() })
+	return g
+}
+
+func newSqliteTestDB(t *testing.T) *GormDB {
+	t.Helper()
+	dir := t.TempDir()
+	rootDir := util.RootDir
+	util.RootDir = func() string { return dir }
+	t.Cleanup(func() { util.RootDir = rootDir })
+
+	g := newSqliteMemoryDB(t)
+	BindModels("lite_test", &liteTestUser{})
+	RegisterDB("lite_test", g)
+	g.MigrateModels(true)
+	return g
+}
+
+func TestSqlite_Curd(t *testing.T) {
+	g := newSqliteTestDB(t)
+	if !g.Migrator().HasTable(&liteTestUser{}) {
+		t.Fatal("table isn't migrated")
+	}
+	table := ModelTableName(&liteTestUser{})
+
+	c := &CurdParams{Table: table, Values: util.Map{"name": "a"}}
+	if err := c.Create(); err != nil {
+		t.Fatal(err)
+	}
+	var user liteTestUser
+	if err := g.First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Version == nil || user.Version.Int64 != 1 || user.CreatedAt == nil {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	c = &CurdParams{Table: table, Values: util.Map{"id": user.ID, "name": "b"}}
+	if err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "b" || user.Version.Int64 != 2 {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	// the stale version isn't updated
+	stale := user
+	stale.Version = &Version{Int64: 1, Valid: true}
+	stale.Name = "c"
+	if n := g.Model(&stale).Select("name", "version").Updates(&stale).RowsAffected; n != 0 {
+		t.Fatalf("stale version updated %d rows", n)
+	}
+}
+
+func TestSqlite_AutoDelete(t *testing.T) {
+	g := newSqliteTestDB(t)
+	for i := 0; i < 5; i++ {
+		if err := g.Create(&liteTestUser{Name: "u"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	(&argsTagModel{AutoDelete: true, Save: "count", Val: 2}).delete(g.DB, &liteTestUser{})
+	var count int64
+	g.Model(&liteTestUser{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+}
+
+func TestDBOption_SqliteDSN(t *testing.T) {
+	rootDir := util.RootDir
+	util.RootDir = func() string { return "/app" }
+	defer func() { util.RootDir = rootDir }()
+
+	cases := []struct {
+		db, path string
+	}{
+		{"local", "/app/data/local.db"},
+		{"local.sqlite", "/app/data/local.sqlite"},
+		{"/tmp/x.db", "/tmp/x.db"},
+	}
+	for _, v := range cases {
+		d := &DBOption{Type: "sqlite3", DB: v.db}
+		if path := d.sqlitePath(); path != v.path {
+			t.Errorf("sqlitePath(%s) = %s, want %s", v.db, path, v.path)
+		}
+	}
+	if d := (&DBOption{Type: "sqlite", DB: sqliteMemory}); !d.isMemory() || d.DSN() != ":memory:?_pragma=foreign_keys(1)" {
+		t.Errorf("unexpected memory dsn %s", d.DSN())
+	}
+}
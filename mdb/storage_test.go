@@ -0,0 +1,13 @@
+package mdb_test
+
+import (
+	"github.com/glibtools/libs/j2rpc"
+	"github.com/glibtools/libs/mdb"
+)
+
+// the stores keep the responses of the j2rpc idempotent calls, the cached responses and the crypto sessions
+var (
+	_ j2rpc.IdempotencyStore   = mdb.ItfStorageCache(nil)
+	_ j2rpc.ResponseCacheStore = mdb.ItfStorageCache(nil)
+	_ j2rpc.CryptoStore        = mdb.ItfStorageCache(nil)
+)
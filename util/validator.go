@@ -56,6 +56,26 @@ func (v *Validator) TranslateZh(es error) (err error) {
 	return
 }
 
+// TranslateFieldsZh translate the validation errors to the field errors,
+// it returns nil when es isn't validator.ValidationErrors
+func (v *Validator) TranslateFieldsZh(es error) []FieldError {
+	e := validator.ValidationErrors{}
+	if !errors.As(es, &e) {
+		return nil
+	}
+	list := make([]FieldError, 0, len(e))
+	for _, fieldError := range e {
+		list = append(list, FieldError{
+			Field:     fieldError.Field(),
+			Namespace: fieldError.Namespace(),
+			Tag:       fieldError.Tag(),
+			Param:     fieldError.Param(),
+			Message:   fieldError.Translate(v.trans),
+		})
+	}
+	return list
+}
+
 // Valid ...
 func (v *Validator) Valid() *validator.Validate { return v.valid }
 
@@ -191,6 +211,15 @@ func (v *Validator) validatorRegValidation(tagName, text string, fn validator.Fu
 	})
 }
 
+// FieldError is the translated validation error of a field
+type FieldError struct {
+	Field     string `json:"field"`
+	Namespace string `json:"namespace"`
+	Tag       string `json:"tag"`
+	Param     string `json:"param,omitempty"`
+	Message   string `json:"message"`
+}
+
 type listError []string
 
 func (l listError) Error() string { return strings.Join(l, ",") }